@@ -1,3 +1,136 @@
 // Package bcc contains functions and types for interacting with the
 // database.
 package bcc
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// DB is a handle to the database that queries can be run against.
+// Both *sqlx.DB and *sqlx.Tx implement it, so every function in this
+// package can be run either on its own or as part of a larger
+// transaction.
+type DB interface {
+	sqlx.QueryerContext
+	sqlx.ExecerContext
+}
+
+// Tx is a DB that is a transaction. *sqlx.Tx implements it.
+type Tx interface {
+	DB
+	Commit() error
+	Rollback() error
+}
+
+// Beginner is a DB that can start transactions. Wrap can be used to
+// turn an *sqlx.DB into one.
+type Beginner interface {
+	DB
+	Begin(ctx context.Context, opts *sql.TxOptions) (Tx, error)
+}
+
+// Wrap returns a Beginner that uses db.
+func Wrap(db *sqlx.DB) Beginner {
+	return sqlxDB{DB: db}
+}
+
+type sqlxDB struct {
+	*sqlx.DB
+}
+
+func (db sqlxDB) Begin(ctx context.Context, opts *sql.TxOptions) (Tx, error) {
+	return db.BeginTxx(ctx, opts)
+}
+
+// SharedTx returns a Beginner that runs everything in tx, so that
+// several callers can share it. Transactions begun on it are tx
+// itself, and committing or rolling them back does nothing, as that
+// is left to whoever began tx. Cache invalidations are passed on to
+// tx, so they still happen when it is committed.
+func SharedTx(tx Tx) Beginner {
+	return sharedTx{Tx: tx}
+}
+
+type sharedTx struct {
+	Tx
+}
+
+func (tx sharedTx) Begin(ctx context.Context, opts *sql.TxOptions) (Tx, error) {
+	return tx, nil
+}
+
+func (tx sharedTx) Commit() error {
+	return nil
+}
+
+func (tx sharedTx) Rollback() error {
+	return nil
+}
+
+func (tx sharedTx) cache() *cache {
+	if c, ok := tx.Tx.(cacher); ok {
+		return c.cache()
+	}
+	return nil
+}
+
+func (tx sharedTx) invalidate(ctx context.Context, keys ...string) {
+	invalidate(ctx, tx.Tx, keys...)
+}
+
+// inTx runs f inside of a transaction, committing it if f returns nil
+// and rolling it back otherwise. If db can't start a transaction, it
+// is presumed to already be one and f is run on it directly.
+func inTx(ctx context.Context, db DB, f func(tx DB) error) error {
+	return inTxOpts(ctx, db, nil, f)
+}
+
+// inTxOpts is like inTx, but begins the transaction with opts.
+func inTxOpts(ctx context.Context, db DB, opts *sql.TxOptions, f func(tx DB) error) (err error) {
+	var b Beginner
+	switch db := db.(type) {
+	case Beginner:
+		b = db
+	case *sqlx.DB:
+		b = Wrap(db)
+	default:
+		return f(db)
+	}
+
+	tx, err := b.Begin(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err = f(tx)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
+	return nil
+}
+
+// idArray converts ids into a value that can be passed to a query as
+// an array, such as for use with = ANY($1).
+func idArray(ids []uint64) pq.Int64Array {
+	a := make(pq.Int64Array, 0, len(ids))
+	for _, id := range ids {
+		a = append(a, int64(id))
+	}
+	return a
+}
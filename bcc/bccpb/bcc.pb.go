@@ -0,0 +1,1081 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: bcc/v1/bcc.proto
+
+// Package bcc.v1 is the gRPC API of the bcc server. Its services mirror
+// the endpoints of the REST API and are backed by the same code.
+
+package bccpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetTimelineRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// ID of the user whose timeline is being fetched.
+	UserId uint64 `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	// Number of timeline entries to skip before returning results.
+	Start int32 `protobuf:"varint,2,opt,name=start,proto3" json:"start,omitempty"`
+	// Maximum number of results to return, at most 100. Defaults to 10.
+	Limit         int32 `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTimelineRequest) Reset() {
+	*x = GetTimelineRequest{}
+	mi := &file_bcc_v1_bcc_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTimelineRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTimelineRequest) ProtoMessage() {}
+
+func (x *GetTimelineRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_bcc_v1_bcc_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTimelineRequest.ProtoReflect.Descriptor instead.
+func (*GetTimelineRequest) Descriptor() ([]byte, []int) {
+	return file_bcc_v1_bcc_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *GetTimelineRequest) GetUserId() uint64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *GetTimelineRequest) GetStart() int32 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *GetTimelineRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type GetTimelineResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Entry         *TimelineEntry         `protobuf:"bytes,1,opt,name=entry,proto3" json:"entry,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTimelineResponse) Reset() {
+	*x = GetTimelineResponse{}
+	mi := &file_bcc_v1_bcc_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTimelineResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTimelineResponse) ProtoMessage() {}
+
+func (x *GetTimelineResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_bcc_v1_bcc_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTimelineResponse.ProtoReflect.Descriptor instead.
+func (*GetTimelineResponse) Descriptor() ([]byte, []int) {
+	return file_bcc_v1_bcc_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetTimelineResponse) GetEntry() *TimelineEntry {
+	if x != nil {
+		return x.Entry
+	}
+	return nil
+}
+
+// TimelineEntry is an entry in a user's timeline. Which of the
+// optional fields are set depends on the type of the entry.
+type TimelineEntry struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// One of "post", "comment", "passed_rating", or "github_event".
+	Type               string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	PostedAt           *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=posted_at,json=postedAt,proto3" json:"posted_at,omitempty"`
+	UpdatedAt          *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	Id                 uint64                 `protobuf:"varint,4,opt,name=id,proto3" json:"id,omitempty"`
+	Title              *string                `protobuf:"bytes,5,opt,name=title,proto3,oneof" json:"title,omitempty"`
+	Body               *string                `protobuf:"bytes,6,opt,name=body,proto3,oneof" json:"body,omitempty"`
+	PostId             *uint64                `protobuf:"varint,7,opt,name=post_id,json=postId,proto3,oneof" json:"post_id,omitempty"`
+	Message            *string                `protobuf:"bytes,8,opt,name=message,proto3,oneof" json:"message,omitempty"`
+	PostUserId         *uint64                `protobuf:"varint,9,opt,name=post_user_id,json=postUserId,proto3,oneof" json:"post_user_id,omitempty"`
+	PostUserName       *string                `protobuf:"bytes,10,opt,name=post_user_name,json=postUserName,proto3,oneof" json:"post_user_name,omitempty"`
+	PostUserRating     *float64               `protobuf:"fixed64,11,opt,name=post_user_rating,json=postUserRating,proto3,oneof" json:"post_user_rating,omitempty"`
+	PassedRatingBefore *float64               `protobuf:"fixed64,12,opt,name=passed_rating_before,json=passedRatingBefore,proto3,oneof" json:"passed_rating_before,omitempty"`
+	PassedRatingAfter  *float64               `protobuf:"fixed64,13,opt,name=passed_rating_after,json=passedRatingAfter,proto3,oneof" json:"passed_rating_after,omitempty"`
+	GithubEventType    *string                `protobuf:"bytes,14,opt,name=github_event_type,json=githubEventType,proto3,oneof" json:"github_event_type,omitempty"`
+	GithubEventRepo    *string                `protobuf:"bytes,15,opt,name=github_event_repo,json=githubEventRepo,proto3,oneof" json:"github_event_repo,omitempty"`
+	GithubEventPr      *uint64                `protobuf:"varint,16,opt,name=github_event_pr,json=githubEventPr,proto3,oneof" json:"github_event_pr,omitempty"`
+	GithubEventCommits *int64                 `protobuf:"varint,17,opt,name=github_event_commits,json=githubEventCommits,proto3,oneof" json:"github_event_commits,omitempty"`
+	GithubEventHead    *string                `protobuf:"bytes,18,opt,name=github_event_head,json=githubEventHead,proto3,oneof" json:"github_event_head,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *TimelineEntry) Reset() {
+	*x = TimelineEntry{}
+	mi := &file_bcc_v1_bcc_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TimelineEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TimelineEntry) ProtoMessage() {}
+
+func (x *TimelineEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_bcc_v1_bcc_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TimelineEntry.ProtoReflect.Descriptor instead.
+func (*TimelineEntry) Descriptor() ([]byte, []int) {
+	return file_bcc_v1_bcc_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *TimelineEntry) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *TimelineEntry) GetPostedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.PostedAt
+	}
+	return nil
+}
+
+func (x *TimelineEntry) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *TimelineEntry) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *TimelineEntry) GetTitle() string {
+	if x != nil && x.Title != nil {
+		return *x.Title
+	}
+	return ""
+}
+
+func (x *TimelineEntry) GetBody() string {
+	if x != nil && x.Body != nil {
+		return *x.Body
+	}
+	return ""
+}
+
+func (x *TimelineEntry) GetPostId() uint64 {
+	if x != nil && x.PostId != nil {
+		return *x.PostId
+	}
+	return 0
+}
+
+func (x *TimelineEntry) GetMessage() string {
+	if x != nil && x.Message != nil {
+		return *x.Message
+	}
+	return ""
+}
+
+func (x *TimelineEntry) GetPostUserId() uint64 {
+	if x != nil && x.PostUserId != nil {
+		return *x.PostUserId
+	}
+	return 0
+}
+
+func (x *TimelineEntry) GetPostUserName() string {
+	if x != nil && x.PostUserName != nil {
+		return *x.PostUserName
+	}
+	return ""
+}
+
+func (x *TimelineEntry) GetPostUserRating() float64 {
+	if x != nil && x.PostUserRating != nil {
+		return *x.PostUserRating
+	}
+	return 0
+}
+
+func (x *TimelineEntry) GetPassedRatingBefore() float64 {
+	if x != nil && x.PassedRatingBefore != nil {
+		return *x.PassedRatingBefore
+	}
+	return 0
+}
+
+func (x *TimelineEntry) GetPassedRatingAfter() float64 {
+	if x != nil && x.PassedRatingAfter != nil {
+		return *x.PassedRatingAfter
+	}
+	return 0
+}
+
+func (x *TimelineEntry) GetGithubEventType() string {
+	if x != nil && x.GithubEventType != nil {
+		return *x.GithubEventType
+	}
+	return ""
+}
+
+func (x *TimelineEntry) GetGithubEventRepo() string {
+	if x != nil && x.GithubEventRepo != nil {
+		return *x.GithubEventRepo
+	}
+	return ""
+}
+
+func (x *TimelineEntry) GetGithubEventPr() uint64 {
+	if x != nil && x.GithubEventPr != nil {
+		return *x.GithubEventPr
+	}
+	return 0
+}
+
+func (x *TimelineEntry) GetGithubEventCommits() int64 {
+	if x != nil && x.GithubEventCommits != nil {
+		return *x.GithubEventCommits
+	}
+	return 0
+}
+
+func (x *TimelineEntry) GetGithubEventHead() string {
+	if x != nil && x.GithubEventHead != nil {
+		return *x.GithubEventHead
+	}
+	return ""
+}
+
+type GetPostRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// ID of the post being fetched.
+	PostId        uint64 `protobuf:"varint,1,opt,name=post_id,json=postId,proto3" json:"post_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetPostRequest) Reset() {
+	*x = GetPostRequest{}
+	mi := &file_bcc_v1_bcc_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetPostRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetPostRequest) ProtoMessage() {}
+
+func (x *GetPostRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_bcc_v1_bcc_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetPostRequest.ProtoReflect.Descriptor instead.
+func (*GetPostRequest) Descriptor() ([]byte, []int) {
+	return file_bcc_v1_bcc_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetPostRequest) GetPostId() uint64 {
+	if x != nil {
+		return x.PostId
+	}
+	return 0
+}
+
+type GetPostResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        uint64                 `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	PostedAt      *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=posted_at,json=postedAt,proto3" json:"posted_at,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	Title         string                 `protobuf:"bytes,4,opt,name=title,proto3" json:"title,omitempty"`
+	Body          string                 `protobuf:"bytes,5,opt,name=body,proto3" json:"body,omitempty"`
+	Comments      []*Comment             `protobuf:"bytes,6,rep,name=comments,proto3" json:"comments,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetPostResponse) Reset() {
+	*x = GetPostResponse{}
+	mi := &file_bcc_v1_bcc_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetPostResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetPostResponse) ProtoMessage() {}
+
+func (x *GetPostResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_bcc_v1_bcc_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetPostResponse.ProtoReflect.Descriptor instead.
+func (*GetPostResponse) Descriptor() ([]byte, []int) {
+	return file_bcc_v1_bcc_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetPostResponse) GetUserId() uint64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *GetPostResponse) GetPostedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.PostedAt
+	}
+	return nil
+}
+
+func (x *GetPostResponse) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *GetPostResponse) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *GetPostResponse) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+func (x *GetPostResponse) GetComments() []*Comment {
+	if x != nil {
+		return x.Comments
+	}
+	return nil
+}
+
+// Comment is a comment on a post.
+type Comment struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        uint64                 `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	PostedAt      *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=posted_at,json=postedAt,proto3" json:"posted_at,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	Id            uint64                 `protobuf:"varint,4,opt,name=id,proto3" json:"id,omitempty"`
+	Message       string                 `protobuf:"bytes,5,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Comment) Reset() {
+	*x = Comment{}
+	mi := &file_bcc_v1_bcc_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Comment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Comment) ProtoMessage() {}
+
+func (x *Comment) ProtoReflect() protoreflect.Message {
+	mi := &file_bcc_v1_bcc_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Comment.ProtoReflect.Descriptor instead.
+func (*Comment) Descriptor() ([]byte, []int) {
+	return file_bcc_v1_bcc_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Comment) GetUserId() uint64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *Comment) GetPostedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.PostedAt
+	}
+	return nil
+}
+
+func (x *Comment) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *Comment) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Comment) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type CreatePostRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// ID of the user making the post.
+	UserId uint64 `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	// Title of the post being made.
+	Title string `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	// Contents of the post being made.
+	Body          string `protobuf:"bytes,3,opt,name=body,proto3" json:"body,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreatePostRequest) Reset() {
+	*x = CreatePostRequest{}
+	mi := &file_bcc_v1_bcc_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreatePostRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreatePostRequest) ProtoMessage() {}
+
+func (x *CreatePostRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_bcc_v1_bcc_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreatePostRequest.ProtoReflect.Descriptor instead.
+func (*CreatePostRequest) Descriptor() ([]byte, []int) {
+	return file_bcc_v1_bcc_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *CreatePostRequest) GetUserId() uint64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *CreatePostRequest) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *CreatePostRequest) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+type CreatePostResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreatePostResponse) Reset() {
+	*x = CreatePostResponse{}
+	mi := &file_bcc_v1_bcc_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreatePostResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreatePostResponse) ProtoMessage() {}
+
+func (x *CreatePostResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_bcc_v1_bcc_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreatePostResponse.ProtoReflect.Descriptor instead.
+func (*CreatePostResponse) Descriptor() ([]byte, []int) {
+	return file_bcc_v1_bcc_proto_rawDescGZIP(), []int{7}
+}
+
+type CreateCommentRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// ID of the user making the comment.
+	UserId uint64 `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	// ID of the post on which a comment is being made.
+	PostId uint64 `protobuf:"varint,2,opt,name=post_id,json=postId,proto3" json:"post_id,omitempty"`
+	// Contents of the comment.
+	Message       string `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateCommentRequest) Reset() {
+	*x = CreateCommentRequest{}
+	mi := &file_bcc_v1_bcc_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateCommentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateCommentRequest) ProtoMessage() {}
+
+func (x *CreateCommentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_bcc_v1_bcc_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateCommentRequest.ProtoReflect.Descriptor instead.
+func (*CreateCommentRequest) Descriptor() ([]byte, []int) {
+	return file_bcc_v1_bcc_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *CreateCommentRequest) GetUserId() uint64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *CreateCommentRequest) GetPostId() uint64 {
+	if x != nil {
+		return x.PostId
+	}
+	return 0
+}
+
+func (x *CreateCommentRequest) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type CreateCommentResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateCommentResponse) Reset() {
+	*x = CreateCommentResponse{}
+	mi := &file_bcc_v1_bcc_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateCommentResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateCommentResponse) ProtoMessage() {}
+
+func (x *CreateCommentResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_bcc_v1_bcc_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateCommentResponse.ProtoReflect.Descriptor instead.
+func (*CreateCommentResponse) Descriptor() ([]byte, []int) {
+	return file_bcc_v1_bcc_proto_rawDescGZIP(), []int{9}
+}
+
+type DeleteCommentRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// ID of the comment being deleted.
+	CommentId     uint64 `protobuf:"varint,1,opt,name=comment_id,json=commentId,proto3" json:"comment_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteCommentRequest) Reset() {
+	*x = DeleteCommentRequest{}
+	mi := &file_bcc_v1_bcc_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteCommentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteCommentRequest) ProtoMessage() {}
+
+func (x *DeleteCommentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_bcc_v1_bcc_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteCommentRequest.ProtoReflect.Descriptor instead.
+func (*DeleteCommentRequest) Descriptor() ([]byte, []int) {
+	return file_bcc_v1_bcc_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *DeleteCommentRequest) GetCommentId() uint64 {
+	if x != nil {
+		return x.CommentId
+	}
+	return 0
+}
+
+type DeleteCommentResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteCommentResponse) Reset() {
+	*x = DeleteCommentResponse{}
+	mi := &file_bcc_v1_bcc_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteCommentResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteCommentResponse) ProtoMessage() {}
+
+func (x *DeleteCommentResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_bcc_v1_bcc_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteCommentResponse.ProtoReflect.Descriptor instead.
+func (*DeleteCommentResponse) Descriptor() ([]byte, []int) {
+	return file_bcc_v1_bcc_proto_rawDescGZIP(), []int{11}
+}
+
+type RateUserRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// ID of the user being rated.
+	UserId uint64 `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	// ID of the user doing the rating.
+	RaterId uint64 `protobuf:"varint,2,opt,name=rater_id,json=raterId,proto3" json:"rater_id,omitempty"`
+	// Rating being given, from 1 to 5.
+	Rating        float64 `protobuf:"fixed64,3,opt,name=rating,proto3" json:"rating,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RateUserRequest) Reset() {
+	*x = RateUserRequest{}
+	mi := &file_bcc_v1_bcc_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RateUserRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RateUserRequest) ProtoMessage() {}
+
+func (x *RateUserRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_bcc_v1_bcc_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RateUserRequest.ProtoReflect.Descriptor instead.
+func (*RateUserRequest) Descriptor() ([]byte, []int) {
+	return file_bcc_v1_bcc_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *RateUserRequest) GetUserId() uint64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *RateUserRequest) GetRaterId() uint64 {
+	if x != nil {
+		return x.RaterId
+	}
+	return 0
+}
+
+func (x *RateUserRequest) GetRating() float64 {
+	if x != nil {
+		return x.Rating
+	}
+	return 0
+}
+
+type RateUserResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RateUserResponse) Reset() {
+	*x = RateUserResponse{}
+	mi := &file_bcc_v1_bcc_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RateUserResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RateUserResponse) ProtoMessage() {}
+
+func (x *RateUserResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_bcc_v1_bcc_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RateUserResponse.ProtoReflect.Descriptor instead.
+func (*RateUserResponse) Descriptor() ([]byte, []int) {
+	return file_bcc_v1_bcc_proto_rawDescGZIP(), []int{13}
+}
+
+var File_bcc_v1_bcc_proto protoreflect.FileDescriptor
+
+const file_bcc_v1_bcc_proto_rawDesc = "" +
+	"\n" +
+	"\x10bcc/v1/bcc.proto\x12\x06bcc.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"Y\n" +
+	"\x12GetTimelineRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\x04R\x06userId\x12\x14\n" +
+	"\x05start\x18\x02 \x01(\x05R\x05start\x12\x14\n" +
+	"\x05limit\x18\x03 \x01(\x05R\x05limit\"B\n" +
+	"\x13GetTimelineResponse\x12+\n" +
+	"\x05entry\x18\x01 \x01(\v2\x15.bcc.v1.TimelineEntryR\x05entry\"\x80\b\n" +
+	"\rTimelineEntry\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x127\n" +
+	"\tposted_at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\bpostedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12\x0e\n" +
+	"\x02id\x18\x04 \x01(\x04R\x02id\x12\x19\n" +
+	"\x05title\x18\x05 \x01(\tH\x00R\x05title\x88\x01\x01\x12\x17\n" +
+	"\x04body\x18\x06 \x01(\tH\x01R\x04body\x88\x01\x01\x12\x1c\n" +
+	"\apost_id\x18\a \x01(\x04H\x02R\x06postId\x88\x01\x01\x12\x1d\n" +
+	"\amessage\x18\b \x01(\tH\x03R\amessage\x88\x01\x01\x12%\n" +
+	"\fpost_user_id\x18\t \x01(\x04H\x04R\n" +
+	"postUserId\x88\x01\x01\x12)\n" +
+	"\x0epost_user_name\x18\n" +
+	" \x01(\tH\x05R\fpostUserName\x88\x01\x01\x12-\n" +
+	"\x10post_user_rating\x18\v \x01(\x01H\x06R\x0epostUserRating\x88\x01\x01\x125\n" +
+	"\x14passed_rating_before\x18\f \x01(\x01H\aR\x12passedRatingBefore\x88\x01\x01\x123\n" +
+	"\x13passed_rating_after\x18\r \x01(\x01H\bR\x11passedRatingAfter\x88\x01\x01\x12/\n" +
+	"\x11github_event_type\x18\x0e \x01(\tH\tR\x0fgithubEventType\x88\x01\x01\x12/\n" +
+	"\x11github_event_repo\x18\x0f \x01(\tH\n" +
+	"R\x0fgithubEventRepo\x88\x01\x01\x12+\n" +
+	"\x0fgithub_event_pr\x18\x10 \x01(\x04H\vR\rgithubEventPr\x88\x01\x01\x125\n" +
+	"\x14github_event_commits\x18\x11 \x01(\x03H\fR\x12githubEventCommits\x88\x01\x01\x12/\n" +
+	"\x11github_event_head\x18\x12 \x01(\tH\rR\x0fgithubEventHead\x88\x01\x01B\b\n" +
+	"\x06_titleB\a\n" +
+	"\x05_bodyB\n" +
+	"\n" +
+	"\b_post_idB\n" +
+	"\n" +
+	"\b_messageB\x0f\n" +
+	"\r_post_user_idB\x11\n" +
+	"\x0f_post_user_nameB\x13\n" +
+	"\x11_post_user_ratingB\x17\n" +
+	"\x15_passed_rating_beforeB\x16\n" +
+	"\x14_passed_rating_afterB\x14\n" +
+	"\x12_github_event_typeB\x14\n" +
+	"\x12_github_event_repoB\x12\n" +
+	"\x10_github_event_prB\x17\n" +
+	"\x15_github_event_commitsB\x14\n" +
+	"\x12_github_event_head\")\n" +
+	"\x0eGetPostRequest\x12\x17\n" +
+	"\apost_id\x18\x01 \x01(\x04R\x06postId\"\xf5\x01\n" +
+	"\x0fGetPostResponse\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\x04R\x06userId\x127\n" +
+	"\tposted_at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\bpostedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12\x14\n" +
+	"\x05title\x18\x04 \x01(\tR\x05title\x12\x12\n" +
+	"\x04body\x18\x05 \x01(\tR\x04body\x12+\n" +
+	"\bcomments\x18\x06 \x03(\v2\x0f.bcc.v1.CommentR\bcomments\"\xc0\x01\n" +
+	"\aComment\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\x04R\x06userId\x127\n" +
+	"\tposted_at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\bpostedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12\x0e\n" +
+	"\x02id\x18\x04 \x01(\x04R\x02id\x12\x18\n" +
+	"\amessage\x18\x05 \x01(\tR\amessage\"V\n" +
+	"\x11CreatePostRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\x04R\x06userId\x12\x14\n" +
+	"\x05title\x18\x02 \x01(\tR\x05title\x12\x12\n" +
+	"\x04body\x18\x03 \x01(\tR\x04body\"\x14\n" +
+	"\x12CreatePostResponse\"b\n" +
+	"\x14CreateCommentRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\x04R\x06userId\x12\x17\n" +
+	"\apost_id\x18\x02 \x01(\x04R\x06postId\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\"\x17\n" +
+	"\x15CreateCommentResponse\"5\n" +
+	"\x14DeleteCommentRequest\x12\x1d\n" +
+	"\n" +
+	"comment_id\x18\x01 \x01(\x04R\tcommentId\"\x17\n" +
+	"\x15DeleteCommentResponse\"]\n" +
+	"\x0fRateUserRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\x04R\x06userId\x12\x19\n" +
+	"\brater_id\x18\x02 \x01(\x04R\araterId\x12\x16\n" +
+	"\x06rating\x18\x03 \x01(\x01R\x06rating\"\x12\n" +
+	"\x10RateUserResponse2[\n" +
+	"\x0fTimelineService\x12H\n" +
+	"\vGetTimeline\x12\x1a.bcc.v1.GetTimelineRequest\x1a\x1b.bcc.v1.GetTimelineResponse0\x012\x8e\x01\n" +
+	"\vPostService\x12:\n" +
+	"\aGetPost\x12\x16.bcc.v1.GetPostRequest\x1a\x17.bcc.v1.GetPostResponse\x12C\n" +
+	"\n" +
+	"CreatePost\x12\x19.bcc.v1.CreatePostRequest\x1a\x1a.bcc.v1.CreatePostResponse2\xac\x01\n" +
+	"\x0eCommentService\x12L\n" +
+	"\rCreateComment\x12\x1c.bcc.v1.CreateCommentRequest\x1a\x1d.bcc.v1.CreateCommentResponse\x12L\n" +
+	"\rDeleteComment\x12\x1c.bcc.v1.DeleteCommentRequest\x1a\x1d.bcc.v1.DeleteCommentResponse2N\n" +
+	"\rRatingService\x12=\n" +
+	"\bRateUser\x12\x17.bcc.v1.RateUserRequest\x1a\x18.bcc.v1.RateUserResponseB8Z6github.com/DeedleFake/backend-code-challenge/bcc/bccpbb\x06proto3"
+
+var (
+	file_bcc_v1_bcc_proto_rawDescOnce sync.Once
+	file_bcc_v1_bcc_proto_rawDescData []byte
+)
+
+func file_bcc_v1_bcc_proto_rawDescGZIP() []byte {
+	file_bcc_v1_bcc_proto_rawDescOnce.Do(func() {
+		file_bcc_v1_bcc_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_bcc_v1_bcc_proto_rawDesc), len(file_bcc_v1_bcc_proto_rawDesc)))
+	})
+	return file_bcc_v1_bcc_proto_rawDescData
+}
+
+var file_bcc_v1_bcc_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
+var file_bcc_v1_bcc_proto_goTypes = []any{
+	(*GetTimelineRequest)(nil),    // 0: bcc.v1.GetTimelineRequest
+	(*GetTimelineResponse)(nil),   // 1: bcc.v1.GetTimelineResponse
+	(*TimelineEntry)(nil),         // 2: bcc.v1.TimelineEntry
+	(*GetPostRequest)(nil),        // 3: bcc.v1.GetPostRequest
+	(*GetPostResponse)(nil),       // 4: bcc.v1.GetPostResponse
+	(*Comment)(nil),               // 5: bcc.v1.Comment
+	(*CreatePostRequest)(nil),     // 6: bcc.v1.CreatePostRequest
+	(*CreatePostResponse)(nil),    // 7: bcc.v1.CreatePostResponse
+	(*CreateCommentRequest)(nil),  // 8: bcc.v1.CreateCommentRequest
+	(*CreateCommentResponse)(nil), // 9: bcc.v1.CreateCommentResponse
+	(*DeleteCommentRequest)(nil),  // 10: bcc.v1.DeleteCommentRequest
+	(*DeleteCommentResponse)(nil), // 11: bcc.v1.DeleteCommentResponse
+	(*RateUserRequest)(nil),       // 12: bcc.v1.RateUserRequest
+	(*RateUserResponse)(nil),      // 13: bcc.v1.RateUserResponse
+	(*timestamppb.Timestamp)(nil), // 14: google.protobuf.Timestamp
+}
+var file_bcc_v1_bcc_proto_depIdxs = []int32{
+	2,  // 0: bcc.v1.GetTimelineResponse.entry:type_name -> bcc.v1.TimelineEntry
+	14, // 1: bcc.v1.TimelineEntry.posted_at:type_name -> google.protobuf.Timestamp
+	14, // 2: bcc.v1.TimelineEntry.updated_at:type_name -> google.protobuf.Timestamp
+	14, // 3: bcc.v1.GetPostResponse.posted_at:type_name -> google.protobuf.Timestamp
+	14, // 4: bcc.v1.GetPostResponse.updated_at:type_name -> google.protobuf.Timestamp
+	5,  // 5: bcc.v1.GetPostResponse.comments:type_name -> bcc.v1.Comment
+	14, // 6: bcc.v1.Comment.posted_at:type_name -> google.protobuf.Timestamp
+	14, // 7: bcc.v1.Comment.updated_at:type_name -> google.protobuf.Timestamp
+	0,  // 8: bcc.v1.TimelineService.GetTimeline:input_type -> bcc.v1.GetTimelineRequest
+	3,  // 9: bcc.v1.PostService.GetPost:input_type -> bcc.v1.GetPostRequest
+	6,  // 10: bcc.v1.PostService.CreatePost:input_type -> bcc.v1.CreatePostRequest
+	8,  // 11: bcc.v1.CommentService.CreateComment:input_type -> bcc.v1.CreateCommentRequest
+	10, // 12: bcc.v1.CommentService.DeleteComment:input_type -> bcc.v1.DeleteCommentRequest
+	12, // 13: bcc.v1.RatingService.RateUser:input_type -> bcc.v1.RateUserRequest
+	1,  // 14: bcc.v1.TimelineService.GetTimeline:output_type -> bcc.v1.GetTimelineResponse
+	4,  // 15: bcc.v1.PostService.GetPost:output_type -> bcc.v1.GetPostResponse
+	7,  // 16: bcc.v1.PostService.CreatePost:output_type -> bcc.v1.CreatePostResponse
+	9,  // 17: bcc.v1.CommentService.CreateComment:output_type -> bcc.v1.CreateCommentResponse
+	11, // 18: bcc.v1.CommentService.DeleteComment:output_type -> bcc.v1.DeleteCommentResponse
+	13, // 19: bcc.v1.RatingService.RateUser:output_type -> bcc.v1.RateUserResponse
+	14, // [14:20] is the sub-list for method output_type
+	8,  // [8:14] is the sub-list for method input_type
+	8,  // [8:8] is the sub-list for extension type_name
+	8,  // [8:8] is the sub-list for extension extendee
+	0,  // [0:8] is the sub-list for field type_name
+}
+
+func init() { file_bcc_v1_bcc_proto_init() }
+func file_bcc_v1_bcc_proto_init() {
+	if File_bcc_v1_bcc_proto != nil {
+		return
+	}
+	file_bcc_v1_bcc_proto_msgTypes[2].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_bcc_v1_bcc_proto_rawDesc), len(file_bcc_v1_bcc_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   14,
+			NumExtensions: 0,
+			NumServices:   4,
+		},
+		GoTypes:           file_bcc_v1_bcc_proto_goTypes,
+		DependencyIndexes: file_bcc_v1_bcc_proto_depIdxs,
+		MessageInfos:      file_bcc_v1_bcc_proto_msgTypes,
+	}.Build()
+	File_bcc_v1_bcc_proto = out.File
+	file_bcc_v1_bcc_proto_goTypes = nil
+	file_bcc_v1_bcc_proto_depIdxs = nil
+}
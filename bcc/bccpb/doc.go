@@ -0,0 +1,8 @@
+// Package bccpb contains the protobuf messages and gRPC services of the
+// bcc API, generated from proto/bcc/v1/bcc.proto.
+//
+// To regenerate it, install buf, protoc-gen-go, and protoc-gen-go-grpc
+// and run go generate.
+package bccpb
+
+//go:generate sh -c "cd ../.. && buf generate"
@@ -0,0 +1,277 @@
+package bcc
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Cache stores the encoded results of queries so that they don't have
+// to be run again. Implementations must be safe for concurrent use.
+//
+// The cache is only an optimization, so errors from it are never
+// returned to the callers of the functions in this package. Reads
+// that fail are treated as misses, and entries that fail to be
+// invalidated expire on their own.
+type Cache interface {
+	// Get returns the value stored under key, if there is one.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+
+	// Set stores val under key for at most ttl.
+	Set(ctx context.Context, key string, val []byte, ttl time.Duration) error
+
+	// Delete removes the values stored under keys, if there are any.
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// WithCache returns a Beginner that uses db for queries and caches
+// the results of some of the functions in this package in c for at
+// most ttl. Those are timeline pages, user ratings, and posts with
+// their comments.
+//
+// The write functions in this package invalidate any entries that
+// they make stale. When they are run in a transaction begun by the
+// returned Beginner, the invalidation is delayed until the transaction
+// is committed. Reads in transactions and with contexts returned by
+// WithPrimary always bypass the cache. Cache misses are read from the
+// primary so that a lagging replica can't refill the cache with data
+// that was just invalidated.
+//
+// Entries can also become stale because of changes that are made
+// without going through the returned Beginner, such as by another
+// process when c is in memory. ttl limits how long such entries stay
+// around.
+func WithCache(db Beginner, c Cache, ttl time.Duration) Beginner {
+	return cachedDB{
+		Beginner: db,
+		c:        &cache{c: c, ttl: ttl},
+	}
+}
+
+type cache struct {
+	c   Cache
+	ttl time.Duration
+}
+
+// load decodes the value stored under key into v. It returns false if
+// there isn't one or if it can't be read.
+func (c *cache) load(ctx context.Context, key string, v interface{}) bool {
+	data, ok, err := c.c.Get(ctx, key)
+	if (err != nil) || !ok {
+		return false
+	}
+	return json.Unmarshal(data, v) == nil
+}
+
+// store encodes v and stores it under key.
+func (c *cache) store(ctx context.Context, key string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	c.c.Set(ctx, key, data, c.ttl)
+}
+
+// version returns the current version of the entries grouped under
+// key. Invalidating key changes the version, orphaning all of the
+// entries that were stored under the old one.
+func (c *cache) version(ctx context.Context, key string) string {
+	data, ok, err := c.c.Get(ctx, key)
+	if (err == nil) && ok {
+		return string(data)
+	}
+
+	v := strconv.FormatInt(time.Now().UnixNano(), 36)
+	c.c.Set(ctx, key, []byte(v), c.ttl)
+	return v
+}
+
+func (c *cache) invalidate(ctx context.Context, keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+	c.c.Delete(ctx, keys...)
+}
+
+// cacher is implemented by DBs that can cache query results.
+type cacher interface {
+	cache() *cache
+}
+
+// invalidator is implemented by DBs that can invalidate cache entries,
+// possibly at a later time.
+type invalidator interface {
+	invalidate(ctx context.Context, keys ...string)
+}
+
+// cacheFor returns the cache to read from when using db, or nil if
+// there isn't one. Entries may have been read from a replica that was
+// behind the primary, so the cache is skipped for contexts that
+// require the primary.
+func cacheFor(ctx context.Context, db DB) *cache {
+	if usePrimary(ctx) {
+		return nil
+	}
+	if c, ok := db.(cacher); ok {
+		return c.cache()
+	}
+	return nil
+}
+
+// fillContext returns a copy of ctx to read data that is about to be
+// stored in the cache with. A replica that is behind the primary could
+// return data that a write has just invalidated, and storing it would
+// keep it around for the full TTL, so misses are read from the
+// primary.
+func fillContext(ctx context.Context) context.Context {
+	return WithPrimary(ctx)
+}
+
+// invalidate invalidates keys in db's cache, if it has one.
+func invalidate(ctx context.Context, db DB, keys ...string) {
+	if i, ok := db.(invalidator); ok {
+		i.invalidate(ctx, keys...)
+	}
+}
+
+type cachedDB struct {
+	Beginner
+	c *cache
+}
+
+func (db cachedDB) cache() *cache {
+	return db.c
+}
+
+func (db cachedDB) invalidate(ctx context.Context, keys ...string) {
+	db.c.invalidate(ctx, keys...)
+}
+
+func (db cachedDB) reader(ctx context.Context) DB {
+	return readDB(ctx, db.Beginner)
+}
+
+func (db cachedDB) Begin(ctx context.Context, opts *sql.TxOptions) (Tx, error) {
+	tx, err := db.Beginner.Begin(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &cachedTx{Tx: tx, c: db.c}, nil
+}
+
+// cachedTx delays invalidations until it is committed so that nothing
+// can put the old data back into the cache in the meantime.
+type cachedTx struct {
+	Tx
+	c *cache
+
+	m       sync.Mutex
+	pending []string
+}
+
+func (tx *cachedTx) invalidate(ctx context.Context, keys ...string) {
+	tx.m.Lock()
+	defer tx.m.Unlock()
+	tx.pending = append(tx.pending, keys...)
+}
+
+func (tx *cachedTx) Commit() error {
+	err := tx.Tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	tx.m.Lock()
+	defer tx.m.Unlock()
+	tx.c.invalidate(context.Background(), tx.pending...)
+	tx.pending = nil
+	return nil
+}
+
+// timelineKey is the key of the version of a user's timeline pages.
+// Pages are stored under keys that include the version, so
+// invalidating it invalidates every page at once.
+func timelineKey(userID uint64) string {
+	return fmt.Sprintf("timeline:%v", userID)
+}
+
+func ratingKey(userID uint64) string {
+	return fmt.Sprintf("rating:%v", userID)
+}
+
+func postKey(postID uint64) string {
+	return fmt.Sprintf("post:%v", postID)
+}
+
+// MemoryCache is a Cache that stores entries in memory. The zero value
+// is ready to use. Expired entries are removed when they are next
+// looked up and whenever more entries have been stored since the last
+// sweep than were left after it.
+type MemoryCache struct {
+	m       sync.Mutex
+	entries map[string]memoryEntry
+	sets    int
+	swept   int
+}
+
+type memoryEntry struct {
+	val     []byte
+	expires time.Time
+}
+
+func (c *MemoryCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return nil, false, nil
+	}
+	return e.val, true, nil
+}
+
+func (c *MemoryCache) Set(ctx context.Context, key string, val []byte, ttl time.Duration) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]memoryEntry)
+	}
+	c.entries[key] = memoryEntry{val: val, expires: time.Now().Add(ttl)}
+
+	c.sets++
+	if c.sets > c.swept {
+		c.sweep()
+	}
+	return nil
+}
+
+// sweep removes expired entries. c.m must be held.
+func (c *MemoryCache) sweep() {
+	now := time.Now()
+	for k, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.sets = 0
+	c.swept = len(c.entries)
+}
+
+func (c *MemoryCache) Delete(ctx context.Context, keys ...string) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	for _, k := range keys {
+		delete(c.entries, k)
+	}
+	return nil
+}
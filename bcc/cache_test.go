@@ -0,0 +1,161 @@
+package bcc
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+)
+
+// fakeDB is a Beginner whose statements all succeed without doing
+// anything. Its queries aren't implemented.
+type fakeDB struct {
+	DB
+}
+
+func (db fakeDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+
+func (db fakeDB) Begin(ctx context.Context, opts *sql.TxOptions) (Tx, error) {
+	return fakeTx{db}, nil
+}
+
+type fakeTx struct {
+	fakeDB
+}
+
+func (tx fakeTx) Commit() error   { return nil }
+func (tx fakeTx) Rollback() error { return nil }
+
+func TestMemoryCache(t *testing.T) {
+	ctx := context.Background()
+	var c MemoryCache
+
+	_, ok, _ := c.Get(ctx, "missing")
+	if ok {
+		t.Fatal("found a key that was never set")
+	}
+
+	c.Set(ctx, "key", []byte("value"), time.Minute)
+	val, ok, _ := c.Get(ctx, "key")
+	if !ok || (string(val) != "value") {
+		t.Fatalf("got %q, %v, want %q, true", val, ok, "value")
+	}
+
+	c.Set(ctx, "expired", []byte("value"), -time.Second)
+	_, ok, _ = c.Get(ctx, "expired")
+	if ok {
+		t.Fatal("found an expired key")
+	}
+	if _, ok := c.entries["expired"]; ok {
+		t.Fatal("expired key was not removed when it was looked up")
+	}
+
+	c.Delete(ctx, "key", "missing")
+	_, ok, _ = c.Get(ctx, "key")
+	if ok {
+		t.Fatal("key was not deleted")
+	}
+}
+
+func TestMemoryCacheSweep(t *testing.T) {
+	ctx := context.Background()
+	var c MemoryCache
+
+	c.Set(ctx, "live", []byte("value"), time.Minute)
+	for _, key := range []string{"a", "b", "c"} {
+		c.Set(ctx, key, []byte("value"), -time.Second)
+	}
+	c.Set(ctx, "trigger", []byte("value"), time.Minute)
+
+	if len(c.entries) != 2 {
+		t.Fatalf("%v entries are left after a sweep, want 2", len(c.entries))
+	}
+}
+
+func TestCachedTxInvalidatesOnCommit(t *testing.T) {
+	tests := []struct {
+		name   string
+		commit bool
+	}{
+		{name: "Commit", commit: true},
+		{name: "Rollback", commit: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			mc := &MemoryCache{}
+			db := WithCache(fakeDB{}, mc, time.Minute)
+
+			keys := []string{timelineKey(1), postKey(2)}
+			for _, key := range keys {
+				mc.Set(ctx, key, []byte("cached"), time.Minute)
+			}
+
+			tx, err := db.Begin(ctx, nil)
+			if err != nil {
+				t.Fatalf("begin: %v", err)
+			}
+			err = CreateComment(ctx, tx, 1, 2, "comment")
+			if err != nil {
+				t.Fatalf("create comment: %v", err)
+			}
+			for _, key := range keys {
+				if _, ok, _ := mc.Get(ctx, key); !ok {
+					t.Fatalf("%q was invalidated before the transaction ended", key)
+				}
+			}
+
+			if test.commit {
+				err = tx.Commit()
+			} else {
+				err = tx.Rollback()
+			}
+			if err != nil {
+				t.Fatalf("end tx: %v", err)
+			}
+			for _, key := range keys {
+				if _, ok, _ := mc.Get(ctx, key); ok == test.commit {
+					t.Fatalf("%q: present is %v after the transaction ended", key, ok)
+				}
+			}
+		})
+	}
+}
+
+func TestSharedTxInvalidatesOnCommit(t *testing.T) {
+	ctx := context.Background()
+	mc := &MemoryCache{}
+	db := WithCache(fakeDB{}, mc, time.Minute)
+	mc.Set(ctx, postKey(2), []byte("cached"), time.Minute)
+
+	tx, err := db.Begin(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+
+	shared := SharedTx(tx)
+	inner, err := shared.Begin(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin shared: %v", err)
+	}
+	err = CreateComment(ctx, inner, 1, 2, "comment")
+	if err != nil {
+		t.Fatalf("create comment: %v", err)
+	}
+	inner.Commit()
+	if _, ok, _ := mc.Get(ctx, postKey(2)); !ok {
+		t.Fatal("committing the shared transaction invalidated the cache")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if _, ok, _ := mc.Get(ctx, postKey(2)); ok {
+		t.Fatal("committing the outer transaction didn't invalidate the cache")
+	}
+}
@@ -0,0 +1,168 @@
+package bcc
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Entry is an entry in a user's timeline. It is one of *PostEntry,
+// *CommentEntry, *RatingMilestoneEntry, or *GitHubEntry. Unlike
+// TimelineEntry, each type only has the fields that apply to it.
+type Entry interface {
+	// EntryType returns the type of the entry. It is one of "post",
+	// "comment", "rating_milestone", or "github_event".
+	EntryType() string
+
+	// Header returns the fields that every type of entry has.
+	Header() EntryHeader
+}
+
+// EntryHeader holds the fields that every type of Entry has.
+type EntryHeader struct {
+	ID        uint64    `json:"id"`
+	PostedAt  time.Time `json:"posted_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (h EntryHeader) Header() EntryHeader {
+	return h
+}
+
+// PostEntry is a post made by the user.
+type PostEntry struct {
+	EntryHeader
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+func (e *PostEntry) EntryType() string {
+	return "post"
+}
+
+// CommentEntry is a comment made by the user on someone's post.
+type CommentEntry struct {
+	EntryHeader
+	PostID   uint64    `json:"post_id"`
+	Message  string    `json:"message"`
+	PostUser EntryUser `json:"post_user"`
+}
+
+func (e *CommentEntry) EntryType() string {
+	return "comment"
+}
+
+// EntryUser identifies a user that an Entry refers to.
+type EntryUser struct {
+	ID   uint64 `json:"id"`
+	Name string `json:"name"`
+
+	// Rating is nil if the user hasn't been rated.
+	Rating *float64 `json:"rating,omitempty"`
+}
+
+// RatingMilestoneEntry is the user's rating rising past 4.
+type RatingMilestoneEntry struct {
+	EntryHeader
+	Before float64 `json:"before"`
+	After  float64 `json:"after"`
+}
+
+func (e *RatingMilestoneEntry) EntryType() string {
+	return "rating_milestone"
+}
+
+// GitHubEntry is an event from the user's GitHub account.
+type GitHubEntry struct {
+	EntryHeader
+	EventType string  `json:"event_type"`
+	Repo      string  `json:"repo"`
+	PR        *uint64 `json:"pr,omitempty"`
+	Commits   *int    `json:"commits,omitempty"`
+	Head      *string `json:"head,omitempty"`
+}
+
+func (e *GitHubEntry) EntryType() string {
+	return "github_event"
+}
+
+// Typed converts e into the Entry type that corresponds to its Type.
+// It returns an error if the type is not one that it knows about.
+func (e TimelineEntry) Typed() (Entry, error) {
+	h := EntryHeader{ID: e.ID, PostedAt: e.PostedAt, UpdatedAt: e.UpdatedAt}
+
+	switch e.Type {
+	case "post":
+		return &PostEntry{
+			EntryHeader: h,
+			Title:       deref(e.Title),
+			Body:        deref(e.Body),
+		}, nil
+
+	case "comment":
+		return &CommentEntry{
+			EntryHeader: h,
+			PostID:      deref(e.PostID),
+			Message:     deref(e.Message),
+			PostUser: EntryUser{
+				ID:     deref(e.PostUserID),
+				Name:   deref(e.PostUserName),
+				Rating: e.PostUserRating,
+			},
+		}, nil
+
+	case "passed_rating":
+		return &RatingMilestoneEntry{
+			EntryHeader: h,
+			Before:      deref(e.PassedRatingBefore),
+			After:       deref(e.PassedRatingAfter),
+		}, nil
+
+	case "github_event":
+		return &GitHubEntry{
+			EntryHeader: h,
+			EventType:   deref(e.GitHubEventType),
+			Repo:        deref(e.GitHubEventRepo),
+			PR:          e.GitHubEventPR,
+			Commits:     e.GitHubEventCommits,
+			Head:        e.GitHubEventHead,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown timeline entry type %q", e.Type)
+	}
+}
+
+// TypedTimeline is like Timeline, but yields typed Entries. The
+// returned Iter stops with an error if it comes across an entry that
+// Typed doesn't know how to convert.
+func TypedTimeline(ctx context.Context, db DB, userID uint64, start, limit int) (*Iter[Entry], error) {
+	iter, err := Timeline(ctx, db, userID, start, limit)
+	if err != nil {
+		return nil, err
+	}
+	return typedEntries(iter), nil
+}
+
+// typedEntries returns an Iter that yields the Typed version of each
+// entry yielded by iter.
+func typedEntries(iter *Iter[TimelineEntry]) *Iter[Entry] {
+	return &Iter[Entry]{
+		next: iter.Next,
+		cur: func() (Entry, error) {
+			return iter.Current().Typed()
+		},
+		done:  iter.Err,
+		close: iter.Close,
+	}
+}
+
+// deref returns the value that p points to, or the zero value if p is
+// nil.
+func deref[T any](p *T) T {
+	if p == nil {
+		var zero T
+		return zero
+	}
+	return *p
+}
@@ -0,0 +1,52 @@
+package bcc
+
+import "testing"
+
+func TestTyped(t *testing.T) {
+	tests := []struct {
+		typ   string
+		entry string
+		err   bool
+	}{
+		{typ: "post", entry: "post"},
+		{typ: "comment", entry: "comment"},
+		{typ: "passed_rating", entry: "rating_milestone"},
+		{typ: "github_event", entry: "github_event"},
+		{typ: "github", err: true},
+		{typ: "", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.typ, func(t *testing.T) {
+			entry, err := TimelineEntry{Type: test.typ}.Typed()
+			if test.err {
+				if err == nil {
+					t.Fatalf("got %T, want an error", entry)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entry.EntryType() != test.entry {
+				t.Errorf("got %q, want %q", entry.EntryType(), test.entry)
+			}
+		})
+	}
+}
+
+func TestTypedEntriesUnknownType(t *testing.T) {
+	entries := []TimelineEntry{{Type: "post"}, {Type: "bogus"}, {Type: "comment"}}
+	iter := typedEntries(sliceIter(entries))
+
+	var n int
+	for iter.Next() {
+		n++
+	}
+	if n != 1 {
+		t.Errorf("got %v entries before stopping, want 1", n)
+	}
+	if iter.Err() == nil {
+		t.Error("no error")
+	}
+}
@@ -0,0 +1,74 @@
+package bcc
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// These are the kinds of errors that functions in this package return
+// when something goes wrong that isn't the fault of the server. They
+// are always wrapped in an *Error, so they should be checked for with
+// errors.Is.
+var (
+	// ErrNotFound means that the requested data doesn't exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrConflict means that the change would conflict with data
+	// that already exists.
+	ErrConflict = errors.New("conflict")
+
+	// ErrForbidden means that the user isn't allowed to do what they
+	// were trying to do.
+	ErrForbidden = errors.New("forbidden")
+
+	// ErrInvalid means that the input was invalid.
+	ErrInvalid = errors.New("invalid")
+
+	// ErrRateLimited means that the user has been doing something too
+	// often and needs to wait before trying again.
+	ErrRateLimited = errors.New("rate limited")
+)
+
+// Error is an error that is of one of the kinds above. Msg describes
+// the problem in a way that is safe to show to the user.
+type Error struct {
+	Kind error
+	Msg  string
+}
+
+func newError(kind error, format string, args ...interface{}) *Error {
+	return &Error{
+		Kind: kind,
+		Msg:  fmt.Sprintf(format, args...),
+	}
+}
+
+func (err *Error) Error() string {
+	return err.Msg
+}
+
+func (err *Error) Unwrap() error {
+	return err.Kind
+}
+
+// dbError converts constraint violations reported by the database into
+// *Errors. Other errors are returned as is.
+func dbError(err error, what string) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	switch pqErr.Code.Name() {
+	case "unique_violation":
+		return newError(ErrConflict, "%v already exists", what)
+	case "foreign_key_violation":
+		return newError(ErrNotFound, "%v refers to something that does not exist", what)
+	case "check_violation", "not_null_violation":
+		return newError(ErrInvalid, "%v is invalid", what)
+	default:
+		return err
+	}
+}
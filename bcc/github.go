@@ -1,9 +1,8 @@
 package bcc
 
 import (
+	"context"
 	"time"
-
-	"github.com/jmoiron/sqlx"
 )
 
 // GitHubEvent mirrors a row of the github_events table.
@@ -24,8 +23,9 @@ type GitHubEvent struct {
 // AddGitHubEvent adds an event to the github_events table. It
 // discards any attempts to add an event with an ID that is already in
 // the table.
-func AddGitHubEvent(db *sqlx.DB, event GitHubEvent) error {
-	_, err := db.Exec(
+func AddGitHubEvent(ctx context.Context, db DB, event GitHubEvent) error {
+	result, err := db.ExecContext(
+		ctx,
 		`
 		INSERT INTO github_events (
 			id,
@@ -49,5 +49,12 @@ func AddGitHubEvent(db *sqlx.DB, event GitHubEvent) error {
 		event.NumCommits,
 		event.Head,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+
+	if n, err := result.RowsAffected(); (err != nil) || (n > 0) {
+		invalidate(ctx, db, timelineKey(event.UserID))
+	}
+	return nil
 }
@@ -0,0 +1,123 @@
+package bcc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// IdempotencyKey identifies a request made with an idempotency key.
+// Keys are scoped to the client that sent them and the endpoint that
+// they were sent to, so clients can't collide by picking the same key.
+type IdempotencyKey struct {
+	Client   string `db:"client"`
+	Key      string `db:"key"`
+	Endpoint string `db:"endpoint"`
+}
+
+// IdempotentRequest mirrors a row of the idempotency_keys table. It
+// records a request that was made with an idempotency key and, once
+// it has been served, the response that was sent to it.
+type IdempotentRequest struct {
+	IdempotencyKey
+	RequestHash []byte    `db:"request_hash"`
+	CreatedAt   time.Time `db:"created_at"`
+
+	// Status is nil until the request has been served.
+	Status      *int    `db:"status"`
+	ContentType *string `db:"content_type"`
+	Body        []byte  `db:"body"`
+}
+
+// ClaimIdempotencyKey records that a request with the given hash is
+// being made with k. Keys are claimed for window, after which they can
+// be claimed again. If the request that claimed a key hasn't been
+// completed after lease, such as because the server serving it
+// stopped, the key can also be claimed again, so lease should be
+// longer than it can take to serve a request.
+//
+// If k was claimed, it returns nil. Otherwise, it returns the request
+// that k is already claimed by, which may still be in progress.
+//
+// The claim is made immediately, so db shouldn't be a transaction.
+func ClaimIdempotencyKey(ctx context.Context, db DB, k IdempotencyKey, hash []byte, window, lease time.Duration) (*IdempotentRequest, error) {
+	var claimed bool
+	err := db.QueryRowxContext(ctx, `
+		INSERT INTO idempotency_keys (client, key, endpoint, request_hash)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (client, key, endpoint) DO UPDATE SET
+			request_hash = EXCLUDED.request_hash,
+			created_at = CURRENT_TIMESTAMP,
+			status = NULL,
+			content_type = NULL,
+			body = NULL
+		WHERE idempotency_keys.created_at < CURRENT_TIMESTAMP - make_interval(secs => $5)
+			OR (idempotency_keys.status IS NULL AND idempotency_keys.created_at < CURRENT_TIMESTAMP - make_interval(secs => $6))
+		RETURNING true
+	`, k.Client, k.Key, k.Endpoint, hash, window.Seconds(), lease.Seconds()).Scan(&claimed)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("claim: %w", err)
+	}
+
+	var prev IdempotentRequest
+	err = db.QueryRowxContext(ctx, `
+		SELECT * FROM idempotency_keys
+		WHERE client = $1 AND key = $2 AND endpoint = $3
+	`, k.Client, k.Key, k.Endpoint).StructScan(&prev)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	return &prev, nil
+}
+
+// CompleteIdempotentRequest records the response to the request that
+// claimed k so that it can be sent again if the request is repeated.
+func CompleteIdempotentRequest(ctx context.Context, db DB, k IdempotencyKey, status int, contentType string, body []byte) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE idempotency_keys SET
+			status = $4,
+			content_type = $5,
+			body = $6
+		WHERE client = $1 AND key = $2 AND endpoint = $3
+	`, k.Client, k.Key, k.Endpoint, status, contentType, body)
+	if err != nil {
+		return fmt.Errorf("update: %w", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey gives up a claim on k, such as when the
+// request that claimed it failed in a way that it is worth retrying.
+func ReleaseIdempotencyKey(ctx context.Context, db DB, k IdempotencyKey) error {
+	_, err := db.ExecContext(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE client = $1 AND key = $2 AND endpoint = $3
+	`, k.Client, k.Key, k.Endpoint)
+	if err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+	return nil
+}
+
+// ExpireIdempotencyKeys deletes the records of requests with
+// idempotency keys that were made before the given time. It returns
+// the number of records that were deleted.
+func ExpireIdempotencyKeys(ctx context.Context, db DB, before time.Time) (int64, error) {
+	r, err := db.ExecContext(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE created_at < $1
+	`, before)
+	if err != nil {
+		return 0, fmt.Errorf("delete: %w", err)
+	}
+	n, err := r.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("rows affected: %w", err)
+	}
+	return n, nil
+}
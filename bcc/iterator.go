@@ -1,41 +1,91 @@
 package bcc
 
-// Iterator iterates over some type of value. It's used much like a
+import (
+	"context"
+	"iter"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Iter iterates over values of type T. It's used much like a
 // bufio.Scanner or an sql.Rows.
 //
-//    for iter.Next() {
-//      cur := iter.Current()
+//	for iter.Next() {
+//	  cur := iter.Current()
 //
-//      // ...
-//    }
-//    if err := iter.Err(); err != nil {
-//      // ...
-//    }
-type Iterator struct {
+//	  // ...
+//	}
+//	if err := iter.Err(); err != nil {
+//	  // ...
+//	}
+//
+// Alternatively, All can be used to range over it directly.
+type Iter[T any] struct {
 	next  func() bool
-	cur   func() (interface{}, error)
+	cur   func() (T, error)
+	done  func() error
 	close func() error
 
-	cache interface{}
+	cache T
 	err   error
 }
 
+// scanRows returns an Iter that scans each row of rows into a T. The
+// Iter stops with ctx's error if ctx is done before the rows run out.
+func scanRows[T any](ctx context.Context, rows *sqlx.Rows) *Iter[T] {
+	return &Iter[T]{
+		next: func() bool {
+			return (ctx.Err() == nil) && rows.Next()
+		},
+		cur: func() (T, error) {
+			var v T
+			err := rows.StructScan(&v)
+			return v, err
+		},
+		done: func() error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return rows.Err()
+		},
+		close: rows.Close,
+	}
+}
+
+// sliceIter returns an Iter that yields the values in s.
+func sliceIter[T any](s []T) *Iter[T] {
+	i := -1
+	return &Iter[T]{
+		next: func() bool {
+			i++
+			return i < len(s)
+		},
+		cur: func() (T, error) {
+			return s[i], nil
+		},
+	}
+}
+
 // Next advances the iterator to the next value. The iterator starts
 // before the first value, so this much be called once before anything
 // else. It returns false if there is no next value to advance to.
-func (iter *Iterator) Next() bool {
+func (iter *Iter[T]) Next() bool {
 	if iter.err != nil {
 		return false
 	}
 
 	more := iter.next()
 	if !more {
+		if iter.done != nil {
+			iter.err = iter.done()
+		}
 		return false
 	}
 
 	v, err := iter.cur()
 	if err != nil {
-		iter.cache = nil
+		var zero T
+		iter.cache = zero
 		iter.err = err
 		return false
 	}
@@ -47,17 +97,175 @@ func (iter *Iterator) Next() bool {
 // Close closes whatever underlying system the iterator is iterating
 // over, if closing it makes sense. This should always be called when
 // the client is done with an iterator.
-func (iter *Iterator) Close() error {
+func (iter *Iter[T]) Close() error {
+	if iter.close == nil {
+		return nil
+	}
 	return iter.close()
 }
 
 // Current returns the current value of the iteration. This value is
 // cached during the call to Next, so this is a cheap call.
-func (iter *Iterator) Current() interface{} {
+func (iter *Iter[T]) Current() T {
 	return iter.cache
 }
 
 // Err returns any errors that caused the iterator to stop early.
-func (iter *Iterator) Err() error {
+func (iter *Iter[T]) Err() error {
 	return iter.err
 }
+
+// All returns a sequence for use with range-over-func. Each value is
+// yielded with a nil error. If iteration stops early because of an
+// error, that error is yielded once with the zero value of T. The
+// iterator is closed when the loop ends.
+//
+//	for v, err := range it.All() {
+//	  if err != nil {
+//	    // ...
+//	  }
+//
+//	  // ...
+//	}
+func (it *Iter[T]) All() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		defer it.Close()
+
+		for it.Next() {
+			if !yield(it.Current(), nil) {
+				return
+			}
+		}
+		if err := it.Err(); err != nil {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
+
+// Untyped returns an Iterator that yields the same values as iter.
+func (iter *Iter[T]) Untyped() *Iterator {
+	return &Iterator{
+		iter: Map(iter, func(v T) interface{} { return v }),
+	}
+}
+
+// Collect reads up to max values from iter into a slice and then
+// closes it. If max is less than or equal to zero, all of the values
+// are read. The returned slice is never nil if err is nil.
+func Collect[T any](iter *Iter[T], max int) (s []T, err error) {
+	defer func() {
+		cerr := iter.Close()
+		if err == nil {
+			err = cerr
+		}
+	}()
+
+	s = []T{}
+	for ((max <= 0) || (len(s) < max)) && iter.Next() {
+		s = append(s, iter.Current())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// CollectMap reads all of the values from iter into a map, keyed by
+// the result of calling key on each, and then closes it.
+func CollectMap[K comparable, T any](iter *Iter[T], key func(T) K) (m map[K]T, err error) {
+	defer func() {
+		cerr := iter.Close()
+		if err == nil {
+			err = cerr
+		}
+	}()
+
+	m = make(map[K]T)
+	for iter.Next() {
+		v := iter.Current()
+		m[key(v)] = v
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Map returns an Iter that yields the result of calling f on each
+// value yielded by iter. Closing the returned Iter closes iter.
+func Map[T, R any](iter *Iter[T], f func(T) R) *Iter[R] {
+	return &Iter[R]{
+		next: iter.Next,
+		cur: func() (R, error) {
+			return f(iter.Current()), nil
+		},
+		done:  iter.Err,
+		close: iter.Close,
+	}
+}
+
+// Filter returns an Iter that yields only the values yielded by iter
+// for which f returns true. Closing the returned Iter closes iter.
+func Filter[T any](iter *Iter[T], f func(T) bool) *Iter[T] {
+	return &Iter[T]{
+		next: func() bool {
+			for iter.Next() {
+				if f(iter.Current()) {
+					return true
+				}
+			}
+			return false
+		},
+		cur: func() (T, error) {
+			return iter.Current(), nil
+		},
+		done:  iter.Err,
+		close: iter.Close,
+	}
+}
+
+// Iterator iterates over some type of value. It's used much like a
+// bufio.Scanner or an sql.Rows.
+//
+//	for iter.Next() {
+//	  cur := iter.Current()
+//
+//	  // ...
+//	}
+//	if err := iter.Err(); err != nil {
+//	  // ...
+//	}
+//
+// Iterator is the untyped predecessor of Iter and is kept for existing
+// callers. New code should use Iter instead.
+type Iterator struct {
+	iter *Iter[interface{}]
+}
+
+// Next advances the iterator to the next value. The iterator starts
+// before the first value, so this much be called once before anything
+// else. It returns false if there is no next value to advance to.
+func (iter *Iterator) Next() bool {
+	return iter.iter.Next()
+}
+
+// Close closes whatever underlying system the iterator is iterating
+// over, if closing it makes sense. This should always be called when
+// the client is done with an iterator.
+func (iter *Iterator) Close() error {
+	return iter.iter.Close()
+}
+
+// Current returns the current value of the iteration. This value is
+// cached during the call to Next, so this is a cheap call.
+func (iter *Iterator) Current() interface{} {
+	return iter.iter.Current()
+}
+
+// Err returns any errors that caused the iterator to stop early.
+func (iter *Iterator) Err() error {
+	return iter.iter.Err()
+}
@@ -0,0 +1,76 @@
+package bcc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// LeaderboardEntry is a single user's place in the leaderboard.
+type LeaderboardEntry struct {
+	// Rank is the 1-based position of the user in the leaderboard.
+	// Users with the same rating are ordered by ID.
+	Rank int `db:"-" json:"rank"`
+
+	UserID     uint64  `db:"user_id" json:"user_id"`
+	Name       string  `db:"name" json:"name"`
+	Rating     float64 `db:"rating" json:"rating"`
+	RaterCount int     `db:"rater_count" json:"rater_count"`
+}
+
+// Leaderboard returns an iterator over LeaderboardEntries, sorted
+// in descending rating order. Only users that have been rated by at
+// least minRaters different users are included.
+//
+// If since is the zero time, ratings from all time are used and the
+// results come straight from the user_ratings table. Otherwise, only
+// each rater's latest rating is considered, and only if it was given
+// at or after since. start and limit work the same way as they do for
+// Timeline.
+func Leaderboard(ctx context.Context, db DB, since time.Time, minRaters, start, limit int) (*Iter[LeaderboardEntry], error) {
+	db = readDB(ctx, db)
+
+	var rows *sqlx.Rows
+	var err error
+	if since.IsZero() {
+		rows, err = db.QueryxContext(ctx, `
+			SELECT
+				user_ratings.user_id AS user_id,
+				users.name AS name,
+				user_ratings.rating AS rating,
+				user_ratings.rater_count AS rater_count
+			FROM user_ratings
+				JOIN users ON users.id = user_ratings.user_id
+				WHERE user_ratings.rater_count >= $1
+			ORDER BY rating DESC, user_id
+			LIMIT $3 OFFSET $2
+		`, minRaters, start, limit)
+	} else {
+		rows, err = db.QueryxContext(ctx, `
+			SELECT
+				latest_ratings.user_id AS user_id,
+				users.name AS name,
+				AVG(latest_ratings.rating) AS rating,
+				COUNT(*) AS rater_count
+			FROM latest_ratings
+				JOIN users ON users.id = latest_ratings.user_id
+				WHERE latest_ratings.rated_at >= $4
+			GROUP BY latest_ratings.user_id, users.name
+			HAVING COUNT(*) >= $1
+			ORDER BY rating DESC, user_id
+			LIMIT $3 OFFSET $2
+		`, minRaters, start, limit, since)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	rank := start
+	return Map(scanRows[LeaderboardEntry](ctx, rows), func(entry LeaderboardEntry) LeaderboardEntry {
+		rank++
+		entry.Rank = rank
+		return entry
+	}), nil
+}
@@ -0,0 +1,107 @@
+package bcc
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx that carries the ID of the
+// request that it was created for. The ID is made available to
+// QueryLogFuncs.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by ctx, or an empty string
+// if there isn't one.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// QueryLogFunc is called by a DB returned from LogQueries after every
+// query with the context that the query was run with, the query, how
+// long it took, and the error it returned, if any. For queries that
+// return rows, the duration only covers the time until the first
+// row was available.
+type QueryLogFunc func(ctx context.Context, query string, dur time.Duration, err error)
+
+// LogQueries returns a Beginner that calls log for every query that is
+// run using it, including queries in transactions that it begins.
+func LogQueries(db Beginner, log QueryLogFunc) Beginner {
+	return loggedBeginner{loggedDB{db: db, log: log}}
+}
+
+type loggedDB struct {
+	db  DB
+	log QueryLogFunc
+}
+
+func (db loggedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := db.db.QueryContext(ctx, query, args...)
+	db.log(ctx, query, time.Since(start), err)
+	return rows, err
+}
+
+func (db loggedDB) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	start := time.Now()
+	rows, err := db.db.QueryxContext(ctx, query, args...)
+	db.log(ctx, query, time.Since(start), err)
+	return rows, err
+}
+
+func (db loggedDB) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
+	start := time.Now()
+	row := db.db.QueryRowxContext(ctx, query, args...)
+	db.log(ctx, query, time.Since(start), row.Err())
+	return row
+}
+
+func (db loggedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	result, err := db.db.ExecContext(ctx, query, args...)
+	db.log(ctx, query, time.Since(start), err)
+	return result, err
+}
+
+type loggedBeginner struct {
+	loggedDB
+}
+
+func (db loggedBeginner) reader(ctx context.Context) DB {
+	r := readDB(ctx, db.db)
+	if r == db.db {
+		return db
+	}
+	if b, ok := r.(Beginner); ok {
+		return loggedBeginner{loggedDB{db: b, log: db.log}}
+	}
+	return loggedDB{db: r, log: db.log}
+}
+
+func (db loggedBeginner) Begin(ctx context.Context, opts *sql.TxOptions) (Tx, error) {
+	tx, err := db.db.(Beginner).Begin(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return loggedTx{loggedDB: loggedDB{db: tx, log: db.log}, tx: tx}, nil
+}
+
+type loggedTx struct {
+	loggedDB
+	tx Tx
+}
+
+func (tx loggedTx) Commit() error {
+	return tx.tx.Commit()
+}
+
+func (tx loggedTx) Rollback() error {
+	return tx.tx.Rollback()
+}
@@ -1,10 +1,11 @@
 package bcc
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"time"
-
-	"github.com/jmoiron/sqlx"
 )
 
 // Post mirrors a row of the posts table.
@@ -18,25 +19,139 @@ type Post struct {
 	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
 }
 
-// GetPostByID retrieves a post from the database by its ID.
-func GetPostByID(db *sqlx.DB, id uint64) (Post, error) {
-	row := db.QueryRowx(`SELECT * FROM posts WHERE id=$1`, id)
+// GetPostByID retrieves a post from the database by its ID. If there
+// is no such post, the returned error wraps ErrNotFound.
+func GetPostByID(ctx context.Context, db DB, id uint64) (Post, error) {
+	row := readDB(ctx, db).QueryRowxContext(ctx, `SELECT * FROM posts WHERE id=$1`, id)
 
 	var post Post
 	err := row.StructScan(&post)
+	if errors.Is(err, sql.ErrNoRows) {
+		return post, newError(ErrNotFound, "post %v does not exist", id)
+	}
 	return post, err
 }
 
+// PostsByID retrieves the posts with the given IDs, keyed by ID. Posts
+// that don't exist are left out of the map.
+func PostsByID(ctx context.Context, db DB, ids []uint64) (map[uint64]Post, error) {
+	rows, err := readDB(ctx, db).QueryxContext(ctx, `SELECT * FROM posts WHERE id = ANY($1)`, idArray(ids))
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return CollectMap(scanRows[Post](ctx, rows), func(p Post) uint64 { return p.ID })
+}
+
+// PostsByUserID returns an iterator of a user's Posts, sorted in
+// descending post time order. start and limit work the same way as
+// they do for Timeline.
+func PostsByUserID(ctx context.Context, db DB, userID uint64, start, limit int) (*Iter[Post], error) {
+	rows, err := readDB(ctx, db).QueryxContext(ctx, `
+		SELECT *
+		FROM posts
+			WHERE user_id = $1
+		ORDER BY posted_at DESC
+		LIMIT $3 OFFSET $2
+	`, userID, start, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return scanRows[Post](ctx, rows), nil
+}
+
+// PostsByUserIDs is like PostsByUserID, but reads the same page of the
+// posts of several users at once, keyed by user ID. Users without any
+// posts on the page are left out of the map.
+func PostsByUserIDs(ctx context.Context, db DB, userIDs []uint64, start, limit int) (map[uint64][]Post, error) {
+	rows, err := readDB(ctx, db).QueryxContext(ctx, `
+		SELECT id, title, body, user_id, posted_at, created_at, updated_at
+		FROM (
+			SELECT
+				*,
+				ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY posted_at DESC) AS n
+			FROM posts
+				WHERE user_id = ANY($1)
+		) AS posts
+			WHERE n > $2 AND n <= $2 + $3
+		ORDER BY user_id, n
+	`, idArray(userIDs), start, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	posts := make(map[uint64][]Post)
+	for p, err := range scanRows[Post](ctx, rows).All() {
+		if err != nil {
+			return nil, err
+		}
+		posts[p.UserID] = append(posts[p.UserID], p)
+	}
+	return posts, nil
+}
+
+// PostWithComments retrieves a post and all of its comments, sorted in
+// ascending post time order. They are read in a single read-only
+// transaction, unless db is already a transaction, so that they agree
+// with each other. If there is no such post, the returned error wraps
+// ErrNotFound.
+func PostWithComments(ctx context.Context, db DB, id uint64) (post Post, comments []Comment, err error) {
+	c := cacheFor(ctx, db)
+
+	type cached struct {
+		Post     Post      `json:"post"`
+		Comments []Comment `json:"comments"`
+	}
+	if c != nil {
+		var v cached
+		if c.load(ctx, postKey(id), &v) {
+			return v.Post, v.Comments, nil
+		}
+		ctx = fillContext(ctx)
+	}
+
+	err = inTxOpts(ctx, db, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}, func(tx DB) error {
+		post, err = GetPostByID(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		iter, err := CommentsByPostID(ctx, tx, id)
+		if err != nil {
+			return fmt.Errorf("comments: %w", err)
+		}
+		comments, err = Collect(iter, 0)
+		if err != nil {
+			return fmt.Errorf("comments iteration: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return Post{}, nil, err
+	}
+
+	if c != nil {
+		c.store(ctx, postKey(id), cached{Post: post, Comments: comments})
+	}
+	return post, comments, nil
+}
+
 // CreatePost creates a post and adds it to the database.
-func CreatePost(db *sqlx.DB, userID uint64, title, body string) error {
-	_, err := db.Exec(`
+func CreatePost(ctx context.Context, db DB, userID uint64, title, body string) error {
+	_, err := db.ExecContext(ctx, `
 		INSERT INTO posts (
 			user_id,
 			title,
 			body
 		) VALUES ($1, $2, $3)
 	`, userID, title, body)
-	return err
+	if err != nil {
+		return dbError(err, "post")
+	}
+
+	invalidate(ctx, db, timelineKey(userID))
+	return nil
 }
 
 // Comment mirrors a row of the comments table.
@@ -50,39 +165,77 @@ type Comment struct {
 	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
 }
 
-// GetCommentsByPostID returns an iterator of Comments on a given
-// post, sorted in ascending post time order.
-func GetCommentsByPostID(db *sqlx.DB, postID uint64) (*Iterator, error) {
-	rows, err := db.Queryx(`SELECT * FROM comments WHERE post_id=$1`, postID)
+// CommentsByPostID returns an iterator of Comments on a given post,
+// sorted in ascending post time order.
+func CommentsByPostID(ctx context.Context, db DB, postID uint64) (*Iter[Comment], error) {
+	rows, err := readDB(ctx, db).QueryxContext(ctx, `SELECT * FROM comments WHERE post_id=$1 ORDER BY commented_at`, postID)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return scanRows[Comment](ctx, rows), nil
+}
+
+// CommentsByPostIDs retrieves the comments on each of the given posts,
+// keyed by post ID and sorted in ascending post time order. Posts
+// without any comments are left out of the map.
+func CommentsByPostIDs(ctx context.Context, db DB, postIDs []uint64) (map[uint64][]Comment, error) {
+	rows, err := readDB(ctx, db).QueryxContext(ctx, `SELECT * FROM comments WHERE post_id = ANY($1) ORDER BY commented_at`, idArray(postIDs))
 	if err != nil {
 		return nil, fmt.Errorf("query: %w", err)
 	}
 
-	return &Iterator{
-		next: rows.Next,
-		cur: func() (interface{}, error) {
-			var comment Comment
-			err := rows.StructScan(&comment)
-			return comment, err
-		},
-		close: rows.Close,
-	}, nil
+	comments := make(map[uint64][]Comment)
+	for c, err := range scanRows[Comment](ctx, rows).All() {
+		if err != nil {
+			return nil, err
+		}
+		comments[c.PostID] = append(comments[c.PostID], c)
+	}
+	return comments, nil
+}
+
+// GetCommentsByPostID returns an iterator of Comments on a given
+// post, sorted in ascending post time order.
+//
+// Deprecated: Use CommentsByPostID instead.
+func GetCommentsByPostID(ctx context.Context, db DB, postID uint64) (*Iterator, error) {
+	iter, err := CommentsByPostID(ctx, db, postID)
+	if err != nil {
+		return nil, err
+	}
+	return iter.Untyped(), nil
 }
 
 // CreateComment creates a comment on a post.
-func CreateComment(db *sqlx.DB, userID, postID uint64, message string) error {
-	_, err := db.Exec(`
+func CreateComment(ctx context.Context, db DB, userID, postID uint64, message string) error {
+	_, err := db.ExecContext(ctx, `
 		INSERT INTO comments (
 			user_id,
 			post_id,
 			message
 		) VALUES ($1, $2, $3)
 	`, userID, postID, message)
-	return err
+	if err != nil {
+		return dbError(err, "comment")
+	}
+
+	invalidate(ctx, db, timelineKey(userID), postKey(postID))
+	return nil
 }
 
-// DeleteComment deletes a comment.
-func DeleteComment(db *sqlx.DB, commentID uint64) error {
-	_, err := db.Exec(`DELETE FROM comments WHERE id = $1`, commentID)
-	return err
+// DeleteComment deletes a comment. If there is no such comment, the
+// returned error wraps ErrNotFound.
+func DeleteComment(ctx context.Context, db DB, commentID uint64) error {
+	var userID, postID uint64
+	err := db.QueryRowxContext(ctx, `DELETE FROM comments WHERE id = $1 RETURNING user_id, post_id`, commentID).Scan(&userID, &postID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return newError(ErrNotFound, "comment %v does not exist", commentID)
+	}
+	if err != nil {
+		return err
+	}
+
+	invalidate(ctx, db, timelineKey(userID), postKey(postID))
+	return nil
 }
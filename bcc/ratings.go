@@ -1,79 +1,391 @@
 package bcc
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"math"
-
-	"github.com/jmoiron/sqlx"
+	"time"
 )
 
-// RateUser adds a rating to the ratings table.
+// rejection is returned when a rating is refused. The reason is a
+// short, stable string that is recorded in the rating_rejections
+// table.
+type rejection struct {
+	reason string
+	err    *Error
+}
+
+func reject(reason string, kind error, format string, args ...interface{}) error {
+	return rejection{
+		reason: reason,
+		err:    newError(kind, format, args...),
+	}
+}
+
+func (r rejection) Error() string {
+	return r.err.Error()
+}
+
+func (r rejection) Unwrap() error {
+	return r.err
+}
+
+// RejectionReason returns the reason that a rating was refused if err
+// was returned by RateUser because of one, such as "cooldown" or
+// "self". The reasons match those recorded in the rating_rejections
+// table.
+func RejectionReason(err error) (string, bool) {
+	var r rejection
+	if !errors.As(err, &r) {
+		return "", false
+	}
+	return r.reason, true
+}
+
+// RatingEvent describes a change in a user's rating that passed a
+// whole star and was recorded in the rating_events table.
+type RatingEvent struct {
+	ID     uint64
+	Before float64
+	After  float64
+}
+
+// RatingPolicy holds the anti-abuse rules that are checked before a
+// rating is accepted. The zero value enforces none of them.
+type RatingPolicy struct {
+	// Cooldown is how long a rater has to wait before rating the same
+	// user again.
+	Cooldown time.Duration
+
+	// DailyCap is the maximum number of ratings that a rater may give
+	// in any 24 hour period. Zero means no limit.
+	DailyCap int
+
+	// MinAccountAge is how long ago the rater must have registered.
+	MinAccountAge time.Duration
+
+	// RequireComment, if true, only allows raters that have commented
+	// on at least one of the rated user's posts.
+	RequireComment bool
+}
+
+// check returns a rejection if the policy does not allow raterID to
+// rate userID. It should be run inside of the transaction that
+// inserts the rating.
+func (p RatingPolicy) check(ctx context.Context, db DB, raterID, userID uint64) error {
+	if p.Cooldown > 0 {
+		var since *float64
+		err := db.QueryRowxContext(ctx, `
+			SELECT EXTRACT(EPOCH FROM CURRENT_TIMESTAMP - MAX(rated_at))
+			FROM ratings
+				WHERE rater_id = $1 AND user_id = $2
+		`, raterID, userID).Scan(&since)
+		if err != nil {
+			return fmt.Errorf("last rating: %w", err)
+		}
+		if since != nil {
+			wait := p.Cooldown - time.Duration(*since*float64(time.Second))
+			if wait > 0 {
+				return reject("cooldown", ErrRateLimited, "user was rated too recently, try again in %v", wait.Round(time.Second))
+			}
+		}
+	}
+
+	if p.DailyCap > 0 {
+		var count int
+		err := db.QueryRowxContext(ctx, `
+			SELECT COUNT(*)
+			FROM ratings
+				WHERE rater_id = $1 AND rated_at > CURRENT_TIMESTAMP - INTERVAL '1 day'
+		`, raterID).Scan(&count)
+		if err != nil {
+			return fmt.Errorf("daily count: %w", err)
+		}
+		if count >= p.DailyCap {
+			return reject("daily_cap", ErrRateLimited, "no more than %v ratings may be given per day", p.DailyCap)
+		}
+	}
+
+	if p.MinAccountAge > 0 {
+		var age float64
+		err := db.QueryRowxContext(ctx, `
+			SELECT EXTRACT(EPOCH FROM CURRENT_TIMESTAMP - registered_at)
+			FROM users
+				WHERE id = $1
+		`, raterID).Scan(&age)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return reject("unknown_rater", ErrForbidden, "rater %v does not exist", raterID)
+			}
+			return fmt.Errorf("account age: %w", err)
+		}
+		if time.Duration(age*float64(time.Second)) < p.MinAccountAge {
+			return reject("account_age", ErrForbidden, "accounts must be at least %v old to rate other users", p.MinAccountAge)
+		}
+	}
+
+	if p.RequireComment {
+		var commented bool
+		err := db.QueryRowxContext(ctx, `
+			SELECT EXISTS (
+				SELECT 1
+				FROM comments
+					JOIN posts ON posts.id = comments.post_id
+					WHERE comments.user_id = $1 AND posts.user_id = $2
+			)
+		`, raterID, userID).Scan(&commented)
+		if err != nil {
+			return fmt.Errorf("interaction: %w", err)
+		}
+		if !commented {
+			return reject("no_interaction", ErrForbidden, "must have commented on one of the user's posts before rating them")
+		}
+	}
+
+	return nil
+}
+
+// RateUser adds a rating to the ratings table after checking it
+// against policy. If the rating is refused, the attempt is recorded in
+// the rating_rejections table and the returned error wraps either
+// ErrRateLimited or ErrForbidden. If db is already a
+// transaction, the record is made in it, so rolling it back will also
+// discard the record.
+//
+// If the rating moved the user's rating past a whole star, the
+// resulting event is returned. Otherwise, the returned event is nil.
 //
 // BUG: It is possible that if two users rate a third at the same time
 // and either rating would have resulted in the user being rated
 // passing four stars, two events claiming as much might get inserted
 // into the database, resulting in an oddity in the user's timeline.
-func RateUser(db *sqlx.DB, raterID, userID uint64, rating float64) (err error) {
+func RateUser(ctx context.Context, db DB, policy RatingPolicy, raterID, userID uint64, rating float64) (*RatingEvent, error) {
+	event, err := rateUser(ctx, db, policy, raterID, userID, rating)
+
+	var r rejection
+	if errors.As(err, &r) {
+		_, aerr := db.ExecContext(ctx, `
+			INSERT INTO rating_rejections (rater_id, user_id, rating, reason)
+			VALUES ($1, $2, $3, $4)
+		`, raterID, userID, rating, r.reason)
+		if aerr != nil {
+			return nil, fmt.Errorf("record rejection: %w", aerr)
+		}
+	}
+
+	return event, err
+}
+
+func rateUser(ctx context.Context, db DB, policy RatingPolicy, raterID, userID uint64, rating float64) (event *RatingEvent, err error) {
 	if raterID == userID {
-		return errors.New("not allowed to rate self")
+		return nil, reject("self", ErrForbidden, "users may not rate themselves")
 	}
 	if (rating < 1) || (rating > 5) {
-		return fmt.Errorf("invalid rating %v", rating)
+		return nil, newError(ErrInvalid, "rating must be between 1 and 5, not %v", rating)
 	}
 
-	tx, err := db.Beginx()
-	if err != nil {
-		return fmt.Errorf("begin tx: %w", err)
-	}
-	defer func() {
+	err = inTx(ctx, db, func(tx DB) error {
+		// Serialize ratings by the same rater so that concurrent requests
+		// can't both slip past the cooldown and the daily cap.
+		_, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, int64(raterID))
+		if err != nil {
+			return fmt.Errorf("lock rater: %w", err)
+		}
+
+		err = policy.check(ctx, tx, raterID, userID)
+		if err != nil {
+			return err
+		}
+
+		before, err := GetRating(ctx, tx, userID)
+		if err != nil {
+			return fmt.Errorf("before: %w", err)
+		}
+
+		var newRow struct {
+			ID      uint64    `db:"id"`
+			RatedAt time.Time `db:"rated_at"`
+		}
+		err = tx.QueryRowxContext(ctx, `
+			INSERT INTO ratings (rater_id, user_id, rating)
+			VALUES ($1, $2, $3)
+			RETURNING id, rated_at
+		`, raterID, userID, rating).StructScan(&newRow)
 		if err != nil {
-			tx.Rollback()
+			return fmt.Errorf("scan new row: %w", err)
 		}
-	}()
 
-	before, err := GetRating(tx, userID)
+		err = updateRatingAggregates(ctx, tx, raterID, userID, rating, newRow.RatedAt)
+		if err != nil {
+			return fmt.Errorf("update aggregates: %w", err)
+		}
+		invalidate(ctx, tx, ratingKey(userID))
+
+		after, err := GetRating(ctx, tx, userID)
+		if err != nil {
+			return fmt.Errorf("after: %w", err)
+		}
+
+		if b, a := math.Floor(before), math.Floor(after); b != a {
+			e := RatingEvent{Before: before, After: after}
+			err = tx.QueryRowxContext(ctx, `
+				INSERT INTO rating_events (rating_id, rating_before, rating_after)
+				VALUES ($1, $2, $3)
+				RETURNING id
+			`, newRow.ID, before, after).Scan(&e.ID)
+			if err != nil {
+				return fmt.Errorf("insert event: %w", err)
+			}
+			event = &e
+
+			invalidate(ctx, tx, timelineKey(userID))
+		}
+
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("before: %w", err)
+		return nil, err
+	}
+	return event, nil
+}
+
+// updateRatingAggregates keeps the latest_ratings and user_ratings
+// tables in sync with a newly inserted rating. latest_ratings holds
+// only the most recent rating from each rater for each user, and
+// user_ratings holds the running sum and count of those.
+func updateRatingAggregates(ctx context.Context, tx DB, raterID, userID uint64, rating float64, ratedAt time.Time) error {
+	var prev *float64
+	err := tx.QueryRowxContext(ctx, `
+		SELECT rating
+		FROM latest_ratings
+			WHERE user_id = $1 AND rater_id = $2
+	`, userID, raterID).Scan(&prev)
+	if (err != nil) && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("previous rating: %w", err)
 	}
 
-	var newRowID uint64
-	err = tx.QueryRowx(`
-		INSERT INTO ratings (rater_id, user_id, rating)
-		VALUES ($1, $2, $3)
-		RETURNING id
-	`, raterID, userID, rating).Scan(&newRowID)
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO latest_ratings (user_id, rater_id, rating, rated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, rater_id) DO UPDATE
+			SET rating = EXCLUDED.rating, rated_at = EXCLUDED.rated_at
+	`, userID, raterID, rating, ratedAt)
 	if err != nil {
-		return fmt.Errorf("scan new row: %w", err)
+		return fmt.Errorf("latest rating: %w", err)
 	}
 
-	after, err := GetRating(tx, userID)
+	delta, newRaters := rating, 1
+	if prev != nil {
+		delta, newRaters = rating-*prev, 0
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO user_ratings (user_id, rating_sum, rater_count, rating)
+		VALUES ($1, $2, 1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+			SET
+				rating_sum = user_ratings.rating_sum + $3,
+				rater_count = user_ratings.rater_count + $4,
+				rating = (user_ratings.rating_sum + $3) / (user_ratings.rater_count + $4),
+				updated_at = CURRENT_TIMESTAMP
+	`, userID, rating, delta, newRaters)
 	if err != nil {
-		return fmt.Errorf("after: %w", err)
+		return fmt.Errorf("user rating: %w", err)
 	}
 
-	if b, a := math.Floor(before), math.Floor(after); b != a {
-		_, err = tx.Exec(`
-			INSERT INTO rating_events (rating_id, rating_before, rating_after)
-			VALUES ($1, $2, $3)
-		`, newRowID, before, after)
+	return nil
+}
+
+// RebuildRatingAggregates recomputes the latest_ratings and
+// user_ratings tables from scratch using the ratings table. It only
+// needs to be called if ratings were inserted without going through
+// RateUser, such as when importing data.
+func RebuildRatingAggregates(ctx context.Context, db DB) error {
+	return inTx(ctx, db, func(tx DB) error {
+		_, err := tx.ExecContext(ctx, `TRUNCATE latest_ratings, user_ratings`)
 		if err != nil {
-			return fmt.Errorf("insert event: %w", err)
+			return fmt.Errorf("truncate: %w", err)
 		}
-	}
 
-	err = tx.Commit()
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO latest_ratings (user_id, rater_id, rating, rated_at)
+			SELECT DISTINCT ON (user_id, rater_id)
+				user_id,
+				rater_id,
+				rating,
+				rated_at
+			FROM ratings
+				ORDER BY user_id, rater_id, rated_at DESC
+		`)
+		if err != nil {
+			return fmt.Errorf("latest ratings: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO user_ratings (user_id, rating_sum, rater_count, rating)
+			SELECT
+				user_id,
+				SUM(rating),
+				COUNT(*),
+				AVG(rating)
+			FROM latest_ratings
+				GROUP BY user_id
+		`)
+		if err != nil {
+			return fmt.Errorf("user ratings: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// UserRating mirrors a row of the user_ratings table, which holds the
+// aggregate of the latest rating given to a user by each rater.
+type UserRating struct {
+	UserID     uint64    `db:"user_id" json:"user_id"`
+	UpdatedAt  time.Time `db:"updated_at" json:"updated_at"`
+	RatingSum  float64   `db:"rating_sum" json:"rating_sum"`
+	RaterCount int       `db:"rater_count" json:"rater_count"`
+	Rating     float64   `db:"rating" json:"rating"`
+}
+
+// RatingsByUserID retrieves the aggregate ratings of the given users,
+// keyed by user ID. Users that haven't been rated are left out of the
+// map.
+func RatingsByUserID(ctx context.Context, db DB, userIDs []uint64) (map[uint64]UserRating, error) {
+	rows, err := readDB(ctx, db).QueryxContext(ctx, `SELECT * FROM user_ratings WHERE user_id = ANY($1)`, idArray(userIDs))
 	if err != nil {
-		return fmt.Errorf("commit: %w", err)
+		return nil, fmt.Errorf("query: %w", err)
 	}
 
-	return nil
+	return CollectMap(scanRows[UserRating](ctx, rows), func(r UserRating) uint64 { return r.UserID })
+}
+
+// GetRating gets the rating of a given user. Users that haven't been
+// rated have a rating of 0.
+func GetRating(ctx context.Context, db DB, userID uint64) (float64, error) {
+	c := cacheFor(ctx, db)
+	if c == nil {
+		return getRating(ctx, db, userID)
+	}
+
+	var rating float64
+	if c.load(ctx, ratingKey(userID), &rating) {
+		return rating, nil
+	}
+
+	rating, err := getRating(fillContext(ctx), db, userID)
+	if err != nil {
+		return 0, err
+	}
+	c.store(ctx, ratingKey(userID), rating)
+	return rating, nil
 }
 
-// GetRating gets the rating of a given user.
-func GetRating(db sqlx.Queryer, userID uint64) (float64, error) {
+func getRating(ctx context.Context, db DB, userID uint64) (float64, error) {
 	var avg *float64
-	err := db.QueryRowx(`
+	err := readDB(ctx, db).QueryRowxContext(ctx, `
 		SELECT
 			AVG(rating)
 		FROM
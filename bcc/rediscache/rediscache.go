@@ -0,0 +1,67 @@
+// Package rediscache implements bcc.Cache on top of Redis or anything
+// else that speaks its protocol, such as Valkey or a local stand-in
+// server.
+package rediscache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache is a bcc.Cache that stores entries in Redis, which allows them
+// to be shared between multiple servers.
+type Cache struct {
+	client *redis.Client
+	prefix string
+}
+
+// New returns a Cache that uses the server at url, such as
+// redis://localhost:6379/0. Every key is prefixed with prefix so that
+// the server can be shared with other applications.
+func New(url, prefix string) (*Cache, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cache{
+		client: redis.NewClient(opts),
+		prefix: prefix,
+	}, nil
+}
+
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	val, err := c.client.Get(ctx, c.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return val, true, nil
+}
+
+func (c *Cache) Set(ctx context.Context, key string, val []byte, ttl time.Duration) error {
+	return c.client.Set(ctx, c.prefix+key, val, ttl).Err()
+}
+
+func (c *Cache) Delete(ctx context.Context, keys ...string) error {
+	prefixed := make([]string, 0, len(keys))
+	for _, k := range keys {
+		prefixed = append(prefixed, c.prefix+k)
+	}
+	return c.client.Del(ctx, prefixed...).Err()
+}
+
+// Ping checks that the server is reachable.
+func (c *Cache) Ping(ctx context.Context) error {
+	return c.client.Ping(ctx).Err()
+}
+
+// Close closes the connections to the server.
+func (c *Cache) Close() error {
+	return c.client.Close()
+}
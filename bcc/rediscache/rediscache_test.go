@@ -0,0 +1,105 @@
+package rediscache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DeedleFake/backend-code-challenge/bcc/rediscache"
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newCache(t *testing.T) (*rediscache.Cache, *miniredis.Miniredis) {
+	t.Helper()
+
+	srv := miniredis.RunT(t)
+	c, err := rediscache.New("redis://"+srv.Addr()+"/0", "test:")
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, srv
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	c, srv := newCache(t)
+
+	err := c.Ping(ctx)
+	if err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+
+	_, ok, err := c.Get(ctx, "missing")
+	if err != nil {
+		t.Fatalf("get missing: %v", err)
+	}
+	if ok {
+		t.Fatal("found a key that was never set")
+	}
+
+	err = c.Set(ctx, "key", []byte("value"), time.Minute)
+	if err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if !srv.Exists("test:key") {
+		t.Fatal("key was not stored with its prefix")
+	}
+
+	val, ok, err := c.Get(ctx, "key")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if !ok || (string(val) != "value") {
+		t.Fatalf("got %q, %v, want %q, true", val, ok, "value")
+	}
+
+	err = c.Set(ctx, "other", []byte("other"), time.Minute)
+	if err != nil {
+		t.Fatalf("set other: %v", err)
+	}
+	err = c.Delete(ctx, "key", "other", "missing")
+	if err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	for _, key := range []string{"key", "other"} {
+		_, ok, err := c.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("get %q after delete: %v", key, err)
+		}
+		if ok {
+			t.Fatalf("%q was not deleted", key)
+		}
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	ctx := context.Background()
+	c, srv := newCache(t)
+
+	err := c.Set(ctx, "key", []byte("value"), time.Minute)
+	if err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if ttl := srv.TTL("test:key"); ttl != time.Minute {
+		t.Fatalf("TTL is %v, want %v", ttl, time.Minute)
+	}
+
+	srv.FastForward(30 * time.Second)
+	_, ok, err := c.Get(ctx, "key")
+	if err != nil {
+		t.Fatalf("get before expiry: %v", err)
+	}
+	if !ok {
+		t.Fatal("key expired early")
+	}
+
+	srv.FastForward(30 * time.Second)
+	_, ok, err = c.Get(ctx, "key")
+	if err != nil {
+		t.Fatalf("get after expiry: %v", err)
+	}
+	if ok {
+		t.Fatal("key did not expire")
+	}
+}
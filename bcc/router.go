@@ -0,0 +1,109 @@
+package bcc
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type primaryKey struct{}
+
+// WithPrimary returns a copy of ctx that causes Routers to send every
+// query made with it to the primary, including reads that would
+// otherwise go to a replica. This can be used to read data that was
+// just written before it has been replicated.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// WithReplicas returns a copy of ctx that undoes WithPrimary, so that
+// reads made with it can go to replicas again. It is for requests that
+// only read even though they were marked as possibly writing.
+func WithReplicas(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, false)
+}
+
+func usePrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
+
+// reader is implemented by DBs that can send read-only queries
+// somewhere other than where they send everything else.
+type reader interface {
+	reader(ctx context.Context) DB
+}
+
+// readDB returns the DB that read-only queries made with ctx should be
+// run on. Functions in this package that only read use it so that
+// their queries can be sent to replicas.
+func readDB(ctx context.Context, db DB) DB {
+	if r, ok := db.(reader); ok {
+		return r.reader(ctx)
+	}
+	return db
+}
+
+// Router is a Beginner that sends queries to a primary database and,
+// where it is safe to, to read replicas. Queries run directly on a
+// Router always go to the primary, as there is no way to tell whether
+// or not they write, but the functions in this package that only read
+// send theirs to the replicas in turn. Read-only transactions are also
+// begun on replicas. Everything else, including every query in a
+// read-write transaction, goes to the primary.
+//
+// Replicas may lag behind the primary, so data that was just written
+// might not be visible when reading from one. WithPrimary can be used
+// to avoid that where it matters.
+type Router struct {
+	primary  Beginner
+	replicas []Beginner
+	next     atomic.Uint64
+}
+
+// NewRouter returns a Router for the given primary and replicas. If
+// there are no replicas, everything goes to the primary.
+func NewRouter(primary Beginner, replicas ...Beginner) *Router {
+	return &Router{
+		primary:  primary,
+		replicas: replicas,
+	}
+}
+
+// replica returns the next replica to use, or the primary if there are
+// none or ctx requires it.
+func (r *Router) replica(ctx context.Context) Beginner {
+	if (len(r.replicas) == 0) || usePrimary(ctx) {
+		return r.primary
+	}
+	return r.replicas[(r.next.Add(1)-1)%uint64(len(r.replicas))]
+}
+
+func (r *Router) reader(ctx context.Context) DB {
+	return r.replica(ctx)
+}
+
+func (r *Router) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return r.primary.QueryContext(ctx, query, args...)
+}
+
+func (r *Router) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	return r.primary.QueryxContext(ctx, query, args...)
+}
+
+func (r *Router) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
+	return r.primary.QueryRowxContext(ctx, query, args...)
+}
+
+func (r *Router) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return r.primary.ExecContext(ctx, query, args...)
+}
+
+func (r *Router) Begin(ctx context.Context, opts *sql.TxOptions) (Tx, error) {
+	if (opts != nil) && opts.ReadOnly {
+		return r.replica(ctx).Begin(ctx, opts)
+	}
+	return r.primary.Begin(ctx, opts)
+}
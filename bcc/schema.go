@@ -0,0 +1,57 @@
+package bcc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// SchemaVersion is the version of the database schema that this
+// package expects. bcc-initdb records it in the schema_version table
+// when it creates the tables. It must be incremented whenever the
+// schema is changed.
+const SchemaVersion = 3
+
+// GetSchemaVersion returns the schema version recorded in the
+// database. If none has been recorded, it returns 0.
+func GetSchemaVersion(ctx context.Context, db DB) (int, error) {
+	var version int
+	err := db.QueryRowxContext(ctx, `SELECT version FROM schema_version WHERE id = 1`).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("query: %w", err)
+	}
+	return version, nil
+}
+
+// SetSchemaVersion records version as the schema version of the
+// database.
+func SetSchemaVersion(ctx context.Context, db DB, version int) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO schema_version (id, version)
+		VALUES (1, $1)
+		ON CONFLICT (id) DO UPDATE SET
+			version = EXCLUDED.version,
+			updated_at = CURRENT_TIMESTAMP
+	`, version)
+	if err != nil {
+		return fmt.Errorf("update: %w", err)
+	}
+	return nil
+}
+
+// CheckSchema returns an error if the schema version recorded in the
+// database is not SchemaVersion.
+func CheckSchema(ctx context.Context, db DB) error {
+	version, err := GetSchemaVersion(ctx, db)
+	if err != nil {
+		return fmt.Errorf("get schema version: %w", err)
+	}
+	if version != SchemaVersion {
+		return fmt.Errorf("schema version is %v, expected %v", version, SchemaVersion)
+	}
+	return nil
+}
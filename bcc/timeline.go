@@ -1,14 +1,17 @@
 package bcc
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"time"
-
-	"github.com/jmoiron/sqlx"
 )
 
 // TimelineEntry is an entry in a user's timeline. Pointer fields may
 // be null depending on the type of the entry. Valid types are "post",
-// "comment", "passed_rating", and "github_event".
+// "comment", "passed_rating", and "github_event". Typed converts it
+// into an Entry, which only has the fields that apply to its type.
 type TimelineEntry struct {
 	Type string `db:"type" json:"type"`
 
@@ -35,134 +38,302 @@ type TimelineEntry struct {
 	GitHubEventHead    *string `db:"github_event_head" json:"github_event_head,omitempty"`
 }
 
-// GetTimeline returns an iterator over the entries in a user's
-// timeline, sorted in descending date order. start and limit control
-// how many rows to return and where to start in the returned rows. In
-// other words, a start of 10 and a limit of 20 will skip 10 rows and
-// then return the 20 following those.
-func GetTimeline(db *sqlx.DB, userID uint64, start, limit int) (*Iterator, error) {
-	rows, err := db.Queryx(`
-		SELECT
-			'post' AS type,
-			posted_at,
-			updated_at,
-			id,
-			title,
-			body,
-			NULL AS message,
-			NULL AS post_id,
-			NULL AS post_user_id,
-			NULL AS post_user_name,
-			NULL AS post_user_rating,
-			NULL :: real AS passed_rating_before,
-			NULL :: real AS passed_rating_after,
-			NULL :: text AS github_event_type,
-			NULL :: text AS github_event_repo,
-			NULL :: bigint AS github_event_pr,
-			NULL :: bigint AS github_event_commits,
-			NULL :: text AS github_event_head
-		FROM posts
-			WHERE user_id = $1
-
-		UNION ALL
-
-		SELECT
-			'comment' AS type,
-			commented_at AS posted_at,
-			comments.updated_at AS updated_at,
-			comments.id AS id,
-			NULL AS title,
-			NULL AS body,
-			message,
-			post_id,
-			users.id AS post_user_id,
-			users.name AS post_user_name,
-			(
-				SELECT AVG(rating) FROM (
-					SELECT
-						ROW_NUMBER() OVER (PARTITION BY rater_id ORDER BY rated_at DESC) AS rn,
-						rating
-					FROM ratings
-						WHERE user_id = posts.user_id
-				) AS r WHERE rn=1
-			) AS post_user_rating,
-			NULL AS passed_rating_before,
-			NULL AS passed_rating_after,
-			NULL AS github_event_type,
-			NULL AS github_event_repo,
-			NULL AS github_event_pr,
-			NULL AS github_event_commits,
-			NULL AS github_event_head
-		FROM comments
-			JOIN posts ON posts.id = comments.post_id
-			JOIN users ON users.id = posts.user_id
-			WHERE comments.user_id = $1
-
-		UNION ALL
-
-		SELECT
-			'passed_rating' AS type,
-			rating_events.rated_at AS posted_at,
-			rating_events.updated_at AS updated_at,
-			rating_events.id AS id,
-			NULL AS title,
-			NULL AS body,
-			NULL AS message,
-			NULL AS post_id,
-			NULL AS post_user_id,
-			NULL AS post_user_name,
-			NULL AS post_user_rating,
-			rating_events.rating_before AS passed_rating_before,
-			rating_events.rating_after AS passed_rating_after,
-			NULL AS github_event_type,
-			NULL AS github_event_repo,
-			NULL AS github_event_pr,
-			NULL AS github_event_commits,
-			NULL AS github_event_head
-		FROM rating_events
-			JOIN ratings ON rating_events.rating_id = ratings.id
-			WHERE user_id = $1
-				AND rating_events.rating_before < 4
-				AND rating_events.rating_after >= 4
-
-		UNION ALL
-
-		SELECT
-			'github_event' AS type,
-			github_events.created_at AS posted_at,
-			github_events.created_at AS updated_at,
-			github_events.id AS id,
-			NULL AS title,
-			NULL AS body,
-			NULL AS message,
-			NULL AS post_id,
-			NULL AS post_user_id,
-			NULL AS post_user_name,
-			NULL AS post_user_rating,
-			NULL AS passed_rating_before,
-			NULL AS passed_rating_after,
-			github_events.type AS github_event_type,
-			github_events.repo_name AS github_event_repo,
-			github_events.pr_number AS github_event_pr,
-			github_events.num_commits AS github_event_commits,
-			github_events.head AS github_event_head
-		FROM github_events
-			WHERE user_id = $1
-
-		ORDER BY posted_at DESC
-		LIMIT $3 OFFSET $2
-	`, userID, start, limit)
+// Timeline returns an iterator over the entries in a user's timeline,
+// sorted in descending date order. start and limit control how many
+// rows to return and where to start in the returned rows. In other
+// words, a start of 10 and a limit of 20 will skip 10 rows and then
+// return the 20 following those.
+//
+// The whole page is read before Timeline returns. See Timelines for
+// details about caching.
+func Timeline(ctx context.Context, db DB, userID uint64, start, limit int) (*Iter[TimelineEntry], error) {
+	entries, err := Timelines(ctx, db, []uint64{userID}, start, limit)
 	if err != nil {
 		return nil, err
 	}
+	return sliceIter(entries[userID]), nil
+}
 
-	return &Iterator{
-		next: rows.Next,
-		cur: func() (interface{}, error) {
-			var entry TimelineEntry
-			err := rows.StructScan(&entry)
-			return entry, err
-		},
-		close: rows.Close,
-	}, nil
+// Timelines is like Timeline, but reads the same page of the timelines
+// of several users at once, keyed by user ID. Every user is in the
+// returned map, even if their page is empty.
+//
+// If db caches results, each user's page is cached separately, and
+// only the pages that aren't in the cache are queried. The ratings of
+// the authors of the posts that comments were made on are cached
+// separately so that pages don't have to be invalidated every time
+// that one of them changes.
+func Timelines(ctx context.Context, db DB, userIDs []uint64, start, limit int) (map[uint64][]TimelineEntry, error) {
+	c := cacheFor(ctx, db)
+	if c == nil {
+		return timelines(ctx, db, userIDs, start, limit)
+	}
+
+	result := make(map[uint64][]TimelineEntry, len(userIDs))
+	keys := make(map[uint64]string, len(userIDs))
+	var missing []uint64
+	for _, id := range userIDs {
+		key := c.timelinePageKey(ctx, id, start, limit)
+		var entries []TimelineEntry
+		if !c.load(ctx, key, &entries) {
+			keys[id] = key
+			missing = append(missing, id)
+			continue
+		}
+
+		err := refreshRatings(ctx, db, entries)
+		if err != nil {
+			return nil, fmt.Errorf("refresh ratings: %w", err)
+		}
+		result[id] = entries
+	}
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	fetched, err := timelines(fillContext(ctx), db, missing, start, limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range missing {
+		c.store(ctx, keys[id], fetched[id])
+		result[id] = fetched[id]
+	}
+
+	return result, nil
+}
+
+// TimelineVersion returns a string that changes whenever the page of
+// a user's timeline that Timeline would return does. It only looks in
+// the cache, so ok is false if db doesn't cache results or if any of
+// the data that the page depends on isn't cached.
+func TimelineVersion(ctx context.Context, db DB, userID uint64, start, limit int) (version string, ok bool) {
+	c := cacheFor(ctx, db)
+	if c == nil {
+		return "", false
+	}
+
+	key := c.timelinePageKey(ctx, userID, start, limit)
+	var entries []TimelineEntry
+	if !c.load(ctx, key, &entries) {
+		return "", false
+	}
+
+	// The page doesn't change when the ratings of the authors of the
+	// posts that comments were made on do, so they have to be checked
+	// separately.
+	h := sha256.New()
+	fmt.Fprint(h, key)
+	seen := make(map[uint64]struct{})
+	for _, e := range entries {
+		if e.PostUserID == nil {
+			continue
+		}
+		if _, ok := seen[*e.PostUserID]; ok {
+			continue
+		}
+		seen[*e.PostUserID] = struct{}{}
+
+		var rating float64
+		if !c.load(ctx, ratingKey(*e.PostUserID), &rating) {
+			return "", false
+		}
+		fmt.Fprintf(h, ":%v=%v", *e.PostUserID, rating)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16]), true
+}
+
+// timelinePageKey returns the key that a page of a user's timeline is
+// cached under. It includes the version of the timeline, so it changes
+// whenever the timeline is invalidated.
+func (c *cache) timelinePageKey(ctx context.Context, userID uint64, start, limit int) string {
+	return fmt.Sprintf("%v:%v:%v:%v", timelineKey(userID), c.version(ctx, timelineKey(userID)), start, limit)
+}
+
+// refreshRatings replaces the post author ratings in entries with
+// their current values.
+func refreshRatings(ctx context.Context, db DB, entries []TimelineEntry) error {
+	ratings := make(map[uint64]*float64)
+	for i := range entries {
+		id := entries[i].PostUserID
+		if id == nil {
+			continue
+		}
+
+		rating, ok := ratings[*id]
+		if !ok {
+			r, err := GetRating(ctx, db, *id)
+			if err != nil {
+				return err
+			}
+			// Ratings are at least 1, so 0 means that the user hasn't been
+			// rated, which the timeline query represents with null.
+			if r != 0 {
+				rating = &r
+			}
+			ratings[*id] = rating
+		}
+		entries[i].PostUserRating = rating
+	}
+	return nil
+}
+
+// timelineRow is a row of the query run by timelines.
+type timelineRow struct {
+	UserID uint64 `db:"timeline_user_id"`
+	N      int    `db:"n"`
+	TimelineEntry
+}
+
+// timelines queries the same page of several users' timelines. Every
+// user is in the returned map.
+func timelines(ctx context.Context, db DB, userIDs []uint64, start, limit int) (map[uint64][]TimelineEntry, error) {
+	rows, err := readDB(ctx, db).QueryxContext(ctx, `
+		SELECT * FROM (
+			SELECT
+				*,
+				ROW_NUMBER() OVER (PARTITION BY timeline_user_id ORDER BY posted_at DESC) AS n
+			FROM (
+				SELECT
+					user_id AS timeline_user_id,
+					'post' AS type,
+					posted_at,
+					updated_at,
+					id,
+					title,
+					body,
+					NULL AS message,
+					NULL AS post_id,
+					NULL AS post_user_id,
+					NULL AS post_user_name,
+					NULL AS post_user_rating,
+					NULL :: real AS passed_rating_before,
+					NULL :: real AS passed_rating_after,
+					NULL :: text AS github_event_type,
+					NULL :: text AS github_event_repo,
+					NULL :: bigint AS github_event_pr,
+					NULL :: bigint AS github_event_commits,
+					NULL :: text AS github_event_head
+				FROM posts
+					WHERE user_id = ANY($1)
+
+				UNION ALL
+
+				SELECT
+					comments.user_id AS timeline_user_id,
+					'comment' AS type,
+					commented_at AS posted_at,
+					comments.updated_at AS updated_at,
+					comments.id AS id,
+					NULL AS title,
+					NULL AS body,
+					message,
+					post_id,
+					users.id AS post_user_id,
+					users.name AS post_user_name,
+					(
+						SELECT AVG(rating) FROM (
+							SELECT
+								ROW_NUMBER() OVER (PARTITION BY rater_id ORDER BY rated_at DESC) AS rn,
+								rating
+							FROM ratings
+								WHERE user_id = posts.user_id
+						) AS r WHERE rn=1
+					) AS post_user_rating,
+					NULL AS passed_rating_before,
+					NULL AS passed_rating_after,
+					NULL AS github_event_type,
+					NULL AS github_event_repo,
+					NULL AS github_event_pr,
+					NULL AS github_event_commits,
+					NULL AS github_event_head
+				FROM comments
+					JOIN posts ON posts.id = comments.post_id
+					JOIN users ON users.id = posts.user_id
+					WHERE comments.user_id = ANY($1)
+
+				UNION ALL
+
+				SELECT
+					ratings.user_id AS timeline_user_id,
+					'passed_rating' AS type,
+					rating_events.rated_at AS posted_at,
+					rating_events.updated_at AS updated_at,
+					rating_events.id AS id,
+					NULL AS title,
+					NULL AS body,
+					NULL AS message,
+					NULL AS post_id,
+					NULL AS post_user_id,
+					NULL AS post_user_name,
+					NULL AS post_user_rating,
+					rating_events.rating_before AS passed_rating_before,
+					rating_events.rating_after AS passed_rating_after,
+					NULL AS github_event_type,
+					NULL AS github_event_repo,
+					NULL AS github_event_pr,
+					NULL AS github_event_commits,
+					NULL AS github_event_head
+				FROM rating_events
+					JOIN ratings ON rating_events.rating_id = ratings.id
+					WHERE ratings.user_id = ANY($1)
+						AND rating_events.rating_before < 4
+						AND rating_events.rating_after >= 4
+
+				UNION ALL
+
+				SELECT
+					github_events.user_id AS timeline_user_id,
+					'github_event' AS type,
+					github_events.created_at AS posted_at,
+					github_events.created_at AS updated_at,
+					github_events.id AS id,
+					NULL AS title,
+					NULL AS body,
+					NULL AS message,
+					NULL AS post_id,
+					NULL AS post_user_id,
+					NULL AS post_user_name,
+					NULL AS post_user_rating,
+					NULL AS passed_rating_before,
+					NULL AS passed_rating_after,
+					github_events.type AS github_event_type,
+					github_events.repo_name AS github_event_repo,
+					github_events.pr_number AS github_event_pr,
+					github_events.num_commits AS github_event_commits,
+					github_events.head AS github_event_head
+				FROM github_events
+					WHERE github_events.user_id = ANY($1)
+			) AS entries
+		) AS entries
+			WHERE n > $2 AND n <= $2 + $3
+		ORDER BY timeline_user_id, n
+	`, idArray(userIDs), start, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	entries := make(map[uint64][]TimelineEntry, len(userIDs))
+	for _, id := range userIDs {
+		entries[id] = []TimelineEntry{}
+	}
+	for row, err := range scanRows[timelineRow](ctx, rows).All() {
+		if err != nil {
+			return nil, err
+		}
+		entries[row.UserID] = append(entries[row.UserID], row.TimelineEntry)
+	}
+	return entries, nil
+}
+
+// GetTimeline returns an iterator over the entries in a user's
+// timeline. See Timeline for details.
+//
+// Deprecated: Use Timeline instead.
+func GetTimeline(ctx context.Context, db DB, userID uint64, start, limit int) (*Iterator, error) {
+	iter, err := Timeline(ctx, db, userID, start, limit)
+	if err != nil {
+		return nil, err
+	}
+	return iter.Untyped(), nil
 }
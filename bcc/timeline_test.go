@@ -0,0 +1,49 @@
+package bcc
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTimelineVersion(t *testing.T) {
+	ctx := context.Background()
+	db := WithCache(fakeDB{}, &MemoryCache{}, time.Minute)
+	c := cacheFor(ctx, db)
+
+	if _, ok := TimelineVersion(ctx, db, 1, 0, 10); ok {
+		t.Fatal("got a version for a page that isn't cached")
+	}
+
+	author := uint64(2)
+	c.store(ctx, c.timelinePageKey(ctx, 1, 0, 10), []TimelineEntry{
+		{Type: "post"},
+		{Type: "comment", PostUserID: &author},
+	})
+	if _, ok := TimelineVersion(ctx, db, 1, 0, 10); ok {
+		t.Fatal("got a version for a page whose author's rating isn't cached")
+	}
+
+	c.store(ctx, ratingKey(author), 3.5)
+	first, ok := TimelineVersion(ctx, db, 1, 0, 10)
+	if !ok {
+		t.Fatal("no version for a cached page")
+	}
+	if v, _ := TimelineVersion(ctx, db, 1, 0, 10); v != first {
+		t.Errorf("version changed from %q to %q without any changes", first, v)
+	}
+	if _, ok := TimelineVersion(WithPrimary(ctx), db, 1, 0, 10); ok {
+		t.Error("got a version for a request that can't use the cache")
+	}
+
+	c.store(ctx, ratingKey(author), 4.5)
+	second, ok := TimelineVersion(ctx, db, 1, 0, 10)
+	if !ok || (second == first) {
+		t.Errorf("got %q, %v after the author's rating changed, want a new version", second, ok)
+	}
+
+	c.invalidate(ctx, timelineKey(1))
+	if _, ok := TimelineVersion(ctx, db, 1, 0, 10); ok {
+		t.Error("got a version after the timeline was invalidated")
+	}
+}
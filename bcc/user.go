@@ -0,0 +1,29 @@
+package bcc
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// User mirrors a row of the users table.
+type User struct {
+	ID             uint64    `db:"id" json:"id"`
+	RegisteredAt   time.Time `db:"registered_at" json:"registered_at"`
+	CreatedAt      time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time `db:"updated_at" json:"updated_at"`
+	Email          string    `db:"email" json:"email"`
+	Name           string    `db:"name" json:"name"`
+	GitHubUsername *string   `db:"github_username" json:"github_username,omitempty"`
+}
+
+// UsersByID retrieves the users with the given IDs, keyed by ID. Users
+// that don't exist are left out of the map.
+func UsersByID(ctx context.Context, db DB, ids []uint64) (map[uint64]User, error) {
+	rows, err := readDB(ctx, db).QueryxContext(ctx, `SELECT * FROM users WHERE id = ANY($1)`, idArray(ids))
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return CollectMap(scanRows[User](ctx, rows), func(u User) uint64 { return u.ID })
+}
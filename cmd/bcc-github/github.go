@@ -9,12 +9,15 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -22,6 +25,8 @@ import (
 	_ "github.com/lib/pq"
 
 	"github.com/DeedleFake/backend-code-challenge/bcc"
+	"github.com/DeedleFake/backend-code-challenge/internal/config"
+	"github.com/DeedleFake/backend-code-challenge/internal/logging"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -49,8 +54,15 @@ type GitHubEvent struct {
 	} `json:"payload"`
 }
 
-func getEvents(user string, token string) ([]GitHubEvent, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("https://api.github.com/users/%v/events", user), nil)
+// getEvents fetches the public events of a GitHub user from the API
+// at the base URL api. It also
+// records the rate limit remaining as reported by the API.
+func getEvents(ctx context.Context, api string, user string, token string) ([]GitHubEvent, error) {
+	u, err := url.JoinPath(api, "users", user, "events")
+	if err != nil {
+		return nil, fmt.Errorf("build URL: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
 	}
@@ -64,6 +76,13 @@ func getEvents(user string, token string) ([]GitHubEvent, error) {
 	}
 	defer rsp.Body.Close()
 
+	if remaining, err := strconv.ParseFloat(rsp.Header.Get("X-RateLimit-Remaining"), 64); err == nil {
+		rateLimitRemaining.Set(remaining)
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %v", rsp.Status)
+	}
+
 	var events []GitHubEvent
 	err = json.NewDecoder(rsp.Body).Decode(&events)
 	if err != nil {
@@ -72,13 +91,19 @@ func getEvents(user string, token string) ([]GitHubEvent, error) {
 	return events, nil
 }
 
-func addEvents(db *sqlx.DB, userID uint64, ghuser string, token string) error {
-	events, err := getEvents(ghuser, token)
+func addEvents(ctx context.Context, db *sqlx.DB, api string, userID uint64, ghuser string, token string) error {
+	log := slog.With("user_id", userID, "github_user", ghuser)
+
+	events, err := getEvents(ctx, api, ghuser, token)
 	if err != nil {
 		return fmt.Errorf("get events: %w", err)
 	}
+	log.DebugContext(ctx, "Got events", "events", len(events))
 
+	var added int
 	for _, event := range events {
+		eventsFetched.WithLabelValues(event.Type).Inc()
+
 		gh := bcc.GitHubEvent{
 			ID:        event.ID,
 			CreatedAt: event.CreatedAt,
@@ -122,38 +147,52 @@ func addEvents(db *sqlx.DB, userID uint64, ghuser string, token string) error {
 			continue
 		}
 
-		err := bcc.AddGitHubEvent(db, gh)
+		err := bcc.AddGitHubEvent(ctx, db, gh)
 		if err != nil {
 			return fmt.Errorf("add %v: %w", gh.ID, err)
 		}
+		added++
+		eventsAdded.WithLabelValues(gh.Type).Inc()
+		log.DebugContext(ctx, "Added event", "event_id", gh.ID, "event_type", gh.Type, "github_event_type", event.Type, "repo", gh.RepoName)
 	}
 
+	log.InfoContext(ctx, "Added events", "added", added, "skipped", len(events)-added)
 	return nil
 }
 
 func main() {
-	dbaddr := flag.String("dbaddr", "localhost", "Database address")
-	dbuser := flag.String("dbuser", "postgres", "Database user")
-	dbpass := flag.String("dbpass", "", "Database password")
-	dbname := flag.String("dbname", "bcc", "Database name")
-	token := flag.String("token", "", "GitHub OAuth 2 token to increase rate limit")
-	flag.Parse()
-
-	db, err := sqlx.Open("postgres", fmt.Sprintf(
-		"postgres://%v:%v@%v/%v?sslmode=disable",
-		*dbuser,
-		*dbpass,
-		*dbaddr,
-		*dbname,
-	))
+	var dbCfg config.DB
+	dbCfg.RegisterFlags(flag.CommandLine)
+	var ghCfg config.GitHub
+	ghCfg.RegisterFlags(flag.CommandLine)
+	pushgateway := flag.String("pushgateway", "", "URL of a Prometheus Pushgateway to push metrics for the run to")
+	metricsFile := flag.String("metrics-file", "", "file to write metrics for the run to in the Prometheus text format, such as for node_exporter's textfile collector")
+	var logOpts logging.Options
+	logOpts.RegisterFlags(flag.CommandLine)
+	err := config.Load(flag.CommandLine, os.Args[1:], &dbCfg, &ghCfg, &logOpts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		os.Exit(2)
+	}
+	logOpts.Setup()
+
+	token, err := ghCfg.GetToken()
+	if err != nil {
+		logging.Fatal("Failed to get GitHub token", "error", err)
+	}
+	httpClient.Timeout = ghCfg.Timeout
+
+	ctx := context.Background()
+
+	db, err := dbCfg.Open()
 	if err != nil {
-		log.Fatalf("Failed to open database connection: %v", err)
+		logging.Fatal("Failed to open database connection", "error", err)
 	}
 	defer db.Close()
 
-	rows, err := db.Queryx(`SELECT id, github_username FROM users WHERE github_username IS NOT NULL`)
+	rows, err := db.QueryxContext(ctx, `SELECT id, github_username FROM users WHERE github_username IS NOT NULL`)
 	if err != nil {
-		log.Fatalf("Failed to get user list: %v", err)
+		logging.Fatal("Failed to get user list", "error", err)
 	}
 	defer rows.Close()
 
@@ -166,22 +205,34 @@ func main() {
 		}
 		err := rows.StructScan(&user)
 		if err != nil {
-			log.Fatalf("Failed to scan: %v", err)
+			logging.Fatal("Failed to scan", "error", err)
 		}
 
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 
-			err := addEvents(db, user.ID, user.GHUsername, *token)
+			err := addEvents(ctx, db, ghCfg.API, user.ID, user.GHUsername, token)
 			if err != nil {
-				log.Printf("Failed to add events for %q (%v): %v", user.GHUsername, user.ID, err)
+				slog.ErrorContext(ctx, "Failed to add events", "user_id", user.ID, "github_user", user.GHUsername, "error", err)
+				usersProcessed.WithLabelValues("failed").Inc()
 				atomic.StoreUint32(&failed, 1)
+				return
 			}
+			usersProcessed.WithLabelValues("ok").Inc()
 		}()
 	}
 	wg.Wait()
 
+	if failed == 0 {
+		lastSuccess.SetToCurrentTime()
+	}
+	err = writeMetrics(*pushgateway, *metricsFile)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to write metrics", "error", err)
+		failed = 1
+	}
+
 	if failed != 0 {
 		os.Exit(1)
 	}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// registry holds the metrics for a single run. The default registry
+// isn't used because the runtime metrics that it includes aren't
+// meaningful for a short-lived job.
+var registry = prometheus.NewRegistry()
+
+var (
+	usersProcessed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "bcc_github",
+		Name:      "users_processed_total",
+		Help:      "Number of users whose events were imported, by result.",
+	}, []string{"result"})
+
+	eventsFetched = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "bcc_github",
+		Name:      "events_fetched_total",
+		Help:      "Number of events fetched from the GitHub API, by GitHub event type.",
+	}, []string{"type"})
+
+	eventsAdded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "bcc_github",
+		Name:      "events_added_total",
+		Help:      "Number of events added to the database, by timeline event type.",
+	}, []string{"type"})
+
+	rateLimitRemaining = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "bcc_github",
+		Name:      "rate_limit_remaining",
+		Help:      "GitHub API requests remaining in the current rate limit window, as of the last request.",
+	})
+
+	lastSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "bcc_github",
+		Name:      "last_success_timestamp_seconds",
+		Help:      "Time at which the last run without failures finished.",
+	})
+)
+
+func init() {
+	registry.MustRegister(
+		usersProcessed,
+		eventsFetched,
+		eventsAdded,
+		rateLimitRemaining,
+		lastSuccess,
+	)
+}
+
+// writeMetrics pushes the metrics for the run to the Pushgateway at
+// url and writes them to the file at path. Either may be empty, in
+// which case it is skipped.
+func writeMetrics(url, path string) error {
+	var errs []error
+
+	if url != "" {
+		err := push.New(url, "bcc_github").Gatherer(registry).Push()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("push to %q: %w", url, err))
+		}
+	}
+
+	if path != "" {
+		err := prometheus.WriteToTextfile(path, registry)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("write to %q: %w", path, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
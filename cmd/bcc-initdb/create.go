@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
+	"github.com/DeedleFake/backend-code-challenge/bcc"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -14,7 +16,16 @@ func createTables(db *sqlx.DB, reset bool) (err error) {
 	tables := []struct {
 		name    string
 		columns []string
+		indexes []string
 	}{
+		{
+			name: "schema_version",
+			columns: []string{
+				"id int NOT NULL PRIMARY KEY CHECK (id = 1)",
+				"updated_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP",
+				"version int NOT NULL",
+			},
+		},
 		{
 			name: "users",
 			columns: []string{
@@ -75,6 +86,43 @@ func createTables(db *sqlx.DB, reset bool) (err error) {
 				"rating_after real NOT NULL",
 			},
 		},
+		{
+			name: "latest_ratings",
+			columns: []string{
+				"user_id bigint NOT NULL",
+				"rater_id bigint NOT NULL",
+				"rating real NOT NULL",
+				"rated_at timestamptz NOT NULL",
+				"PRIMARY KEY (user_id, rater_id)",
+			},
+			indexes: []string{
+				"rated_at",
+			},
+		},
+		{
+			name: "user_ratings",
+			columns: []string{
+				"user_id bigint NOT NULL PRIMARY KEY",
+				"updated_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP",
+				"rating_sum double precision NOT NULL",
+				"rater_count int NOT NULL",
+				"rating double precision NOT NULL",
+			},
+			indexes: []string{
+				"rating DESC, user_id",
+			},
+		},
+		{
+			name: "rating_rejections",
+			columns: []string{
+				"id bigserial NOT NULL PRIMARY KEY",
+				"rejected_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP",
+				"rater_id bigint NOT NULL",
+				"user_id bigint NOT NULL",
+				"rating real NOT NULL",
+				"reason text NOT NULL",
+			},
+		},
 		{
 			name: "github_events",
 			columns: []string{
@@ -88,6 +136,23 @@ func createTables(db *sqlx.DB, reset bool) (err error) {
 				"head text",
 			},
 		},
+		{
+			name: "idempotency_keys",
+			columns: []string{
+				"client text NOT NULL",
+				"key text NOT NULL",
+				"endpoint text NOT NULL",
+				"request_hash bytea NOT NULL",
+				"created_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP",
+				"status int",
+				"content_type text",
+				"body bytea",
+				"PRIMARY KEY (client, key, endpoint)",
+			},
+			indexes: []string{
+				"created_at",
+			},
+		},
 	}
 
 	if reset {
@@ -106,6 +171,18 @@ func createTables(db *sqlx.DB, reset bool) (err error) {
 		if err != nil {
 			return fmt.Errorf("create %q: %w", table.name, err)
 		}
+
+		for i, index := range table.indexes {
+			_, err := db.Exec(fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %v_idx%v ON %v (%v)`, table.name, i, table.name, index))
+			if err != nil {
+				return fmt.Errorf("index %q on %q: %w", index, table.name, err)
+			}
+		}
+	}
+
+	err = bcc.SetSchemaVersion(context.Background(), db, bcc.SchemaVersion)
+	if err != nil {
+		return fmt.Errorf("set schema version: %w", err)
 	}
 
 	return nil
@@ -2,13 +2,17 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"os"
 	"strings"
 	"sync"
 
-	"github.com/jmoiron/sqlx"
+	"github.com/DeedleFake/backend-code-challenge/bcc"
+	"github.com/DeedleFake/backend-code-challenge/internal/config"
+	"github.com/DeedleFake/backend-code-challenge/internal/logging"
 	_ "github.com/lib/pq"
 )
 
@@ -47,32 +51,32 @@ func (df *dataFlag) Set(val string) error {
 }
 
 func main() {
-	addr := flag.String("dbaddr", "localhost", "Database address")
-	user := flag.String("dbuser", "postgres", "Database user")
-	pw := flag.String("dbpass", "", "Database password")
-	name := flag.String("dbname", "bcc", "Database name")
+	var dbCfg config.DB
+	dbCfg.RegisterFlags(flag.CommandLine)
 	reset := flag.Bool("reset", false, "Reset all tables")
 
 	var data dataFlag
 	flag.Var(&data, "data", "Comma separated list of table names and CSV files with data to insert into them")
 
-	flag.Parse()
+	var logOpts logging.Options
+	logOpts.RegisterFlags(flag.CommandLine)
 
-	db, err := sqlx.Open("postgres", fmt.Sprintf(
-		"postgres://%v:%v@%v/%v?sslmode=disable",
-		*user,
-		*pw,
-		*addr,
-		*name,
-	))
+	err := config.Load(flag.CommandLine, os.Args[1:], &dbCfg, &logOpts)
 	if err != nil {
-		log.Fatalf("Failed to open database connection: %v", err)
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		os.Exit(2)
+	}
+	logOpts.Setup()
+
+	db, err := dbCfg.Open()
+	if err != nil {
+		logging.Fatal("Failed to open database connection", "error", err)
 	}
 	defer db.Close()
 
-	createTables(db, *reset)
+	err = createTables(db, *reset)
 	if err != nil {
-		log.Fatalf("Failed to create tables: %v", err)
+		logging.Fatal("Failed to create tables", "error", err)
 	}
 
 	var wg sync.WaitGroup
@@ -83,9 +87,18 @@ func main() {
 
 			err := insertData(db, table, path)
 			if err != nil {
-				log.Printf("Failed to insert data into %q: %v", table, err)
+				slog.Error("Failed to insert data", "table", table, "path", path, "error", err)
+				return
 			}
+			slog.Info("Inserted data", "table", table, "path", path)
 		}(table, path)
 	}
 	wg.Wait()
+
+	if len(data) > 0 {
+		err := bcc.RebuildRatingAggregates(context.Background(), db)
+		if err != nil {
+			logging.Fatal("Failed to rebuild rating aggregates", "error", err)
+		}
+	}
 }
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/DeedleFake/backend-code-challenge/bcc"
+)
+
+type BatchParams struct {
+	Requests []BatchRequest `json:"requests" validate:"required,maxlen=50" desc:"requests to run, at most 50"`
+	Atomic   bool           `json:"atomic" desc:"run every request that isn't a GET in a single transaction, in order, so that either all of them succeed or none of them have any effect"`
+}
+
+// BatchRequest is a request in a BatchParams.
+type BatchRequest struct {
+	Method string `json:"method" desc:"HTTP method of the request"`
+	Path   string `json:"path" desc:"path of the endpoint, optionally prefixed with a version, such as /v2/timeline"`
+
+	// Params are sent as query parameters for GET and DELETE requests
+	// and as the body otherwise.
+	Params map[string]json.RawMessage `json:"params" desc:"parameters of the request"`
+}
+
+// BatchResponse is the response to a POST /batch request.
+type BatchResponse struct {
+	Responses []BatchResponseItem `json:"responses"`
+}
+
+// BatchResponseItem is the response to a single BatchRequest. Body is
+// what the endpoint would have sent back on its own, including
+// Problems for failed requests.
+type BatchResponseItem struct {
+	Status int         `json:"status"`
+	Body   interface{} `json:"body"`
+}
+
+type batchKey struct{}
+
+// BatchHandler runs several requests through the endpoints of an
+// APIMux at once, so that clients can make them in a single round
+// trip. Each request is served as if it had been made on its own in
+// the same version of the API as the batch, except that the mux's
+// Middleware isn't applied.
+type BatchHandler struct {
+	// Mux serves the requests in the batch. It is set after the
+	// handler is created, as the mux's endpoints include the handler.
+	Mux *APIMux
+
+	// Concurrency is the most requests from a single batch that are
+	// served at once. If it is less than 1, they are served one at a
+	// time.
+	Concurrency int
+}
+
+func (h *BatchHandler) Desc() string {
+	return "run several requests at once"
+}
+
+func (h *BatchHandler) Params() interface{} {
+	return &BatchParams{}
+}
+
+func (h *BatchHandler) Response() interface{} {
+	return BatchResponse{}
+}
+
+func (h *BatchHandler) Serve(req *http.Request, db bcc.DB, params interface{}) (interface{}, error) {
+	q := params.(*BatchParams)
+
+	if req.Context().Value(batchKey{}) != nil {
+		return nil, BadRequest(errors.New("batches can't be nested"))
+	}
+	addLogAttrs(req.Context(), slog.Int("batch_size", len(q.Requests)), slog.Bool("batch_atomic", q.Atomic))
+
+	items := make([]BatchResponseItem, len(q.Requests))
+
+	sem := make(chan struct{}, max(h.Concurrency, 1))
+	var wg sync.WaitGroup
+	run := func(mux *APIMux, i int) {
+		sem <- struct{}{}
+		defer func() { <-sem }()
+		items[i] = h.serveOne(req, mux, q.Requests[i])
+	}
+
+	var writes []int
+	for i, r := range q.Requests {
+		if q.Atomic && !strings.EqualFold(r.Method, "GET") {
+			writes = append(writes, i)
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			run(h.Mux, i)
+		}()
+	}
+
+	err := h.serveAtomic(req, writes, items, run)
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	return BatchResponse{Responses: items}, nil
+}
+
+// serveAtomic serves the requests at the given indices in order in a
+// single transaction, stopping at the first one that fails. If one
+// does, the transaction is rolled back and the items of the rest are
+// replaced with Problems saying so.
+func (h *BatchHandler) serveAtomic(req *http.Request, writes []int, items []BatchResponseItem, run func(*APIMux, int)) (err error) {
+	if len(writes) == 0 {
+		return nil
+	}
+
+	tx, err := h.Mux.DB.Begin(req.Context(), &sql.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	mux := *h.Mux
+	mux.DB = bcc.SharedTx(tx)
+
+	failed := -1
+	for _, i := range writes {
+		run(&mux, i)
+		if items[i].Status >= 400 {
+			failed = i
+			break
+		}
+	}
+
+	if failed < 0 {
+		err = tx.Commit()
+		if err != nil {
+			return fmt.Errorf("commit: %w", err)
+		}
+		committed = true
+		return nil
+	}
+
+	for _, i := range writes {
+		var detail string
+		switch {
+		case i == failed:
+			continue
+		case items[i].Status == 0:
+			detail = "not run because another request in the batch failed"
+		default:
+			detail = "rolled back because another request in the batch failed"
+		}
+		p := newProblem(http.StatusFailedDependency, "batch_aborted", detail)
+		p.RequestID = bcc.RequestID(req.Context())
+		items[i] = BatchResponseItem{Status: p.Status, Body: p}
+	}
+	return nil
+}
+
+// serveOne serves r through mux as part of the batch request req.
+func (h *BatchHandler) serveOne(req *http.Request, mux *APIMux, r BatchRequest) BatchResponseItem {
+	sub, err := newBatchSubrequest(req, r)
+	if err != nil {
+		p := newProblem(http.StatusBadRequest, "bad_request", err.Error())
+		p.RequestID = bcc.RequestID(req.Context())
+		return BatchResponseItem{Status: p.Status, Body: p}
+	}
+
+	var rec batchRecorder
+	mux.version(Recover(http.HandlerFunc(mux.route))).ServeHTTP(&rec, sub)
+
+	item := BatchResponseItem{Status: rec.status()}
+	if rec.body.Len() != 0 {
+		err := json.Unmarshal(rec.body.Bytes(), &item.Body)
+		if err != nil {
+			slog.WarnContext(req.Context(), "Failed to decode batch response", "path", r.Path, "error", err)
+		}
+	}
+	return item
+}
+
+// newBatchSubrequest returns a request for r that inherits the context,
+// client, and API version of the batch request req, so that it is
+// rate limited as the same client. The sub-request gets a request log
+// of its own so that its attributes don't overwrite those of the batch
+// in the access log.
+func newBatchSubrequest(req *http.Request, r BatchRequest) (*http.Request, error) {
+	method := strings.ToUpper(r.Method)
+	u, err := url.Parse(r.Path)
+	if (err != nil) || !strings.HasPrefix(u.Path, "/") {
+		return nil, fmt.Errorf("invalid path %q", r.Path)
+	}
+
+	var body bytes.Buffer
+	switch method {
+	case "GET", "DELETE":
+		query := u.Query()
+		for k, v := range r.Params {
+			var s string
+			if json.Unmarshal(v, &s) != nil {
+				s = string(v)
+			}
+			query.Set(k, s)
+		}
+		u.RawQuery = query.Encode()
+
+	default:
+		params := r.Params
+		if params == nil {
+			params = map[string]json.RawMessage{}
+		}
+		err := json.NewEncoder(&body).Encode(params)
+		if err != nil {
+			return nil, fmt.Errorf("encode params: %w", err)
+		}
+	}
+
+	ctx := context.WithValue(req.Context(), batchKey{}, true)
+	if method == "GET" {
+		// The batch is a POST, so Consistency sent all of its reads to
+		// the primary.
+		ctx = readContext(ctx)
+	}
+	ctx, _ = withRequestLog(ctx)
+	sub, err := http.NewRequestWithContext(ctx, method, u.String(), &body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	sub.RemoteAddr = req.RemoteAddr
+	sub.Header["X-Forwarded-For"] = req.Header["X-Forwarded-For"]
+	sub.Header.Set("Content-Type", "application/json")
+	if v := apiVersion(req.Context()); v.Number != 0 {
+		sub.Header.Set("API-Version", strconv.FormatInt(int64(v.Number), 10))
+	}
+	return sub, nil
+}
+
+// batchRecorder is an http.ResponseWriter that keeps the response to a
+// request in a batch.
+type batchRecorder struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (rec *batchRecorder) Header() http.Header {
+	if rec.header == nil {
+		rec.header = make(http.Header)
+	}
+	return rec.header
+}
+
+func (rec *batchRecorder) WriteHeader(status int) {
+	if rec.code == 0 {
+		rec.code = status
+	}
+}
+
+func (rec *batchRecorder) Write(data []byte) (int, error) {
+	rec.WriteHeader(http.StatusOK)
+	return rec.body.Write(data)
+}
+
+func (rec *batchRecorder) status() int {
+	if rec.code == 0 {
+		return http.StatusOK
+	}
+	return rec.code
+}
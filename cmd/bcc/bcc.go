@@ -4,17 +4,163 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
+	"os"
+	"os/signal"
 	"reflect"
+	"slices"
 	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
-	"github.com/jmoiron/sqlx"
+	"github.com/DeedleFake/backend-code-challenge/bcc"
+	"github.com/DeedleFake/backend-code-challenge/bcc/rediscache"
+	"github.com/DeedleFake/backend-code-challenge/internal/config"
+	"github.com/DeedleFake/backend-code-challenge/internal/logging"
+	"github.com/DeedleFake/backend-code-challenge/internal/ratelimit"
 	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
+// timeoutsFlag is an implementation of flag.Value that reads a
+// comma-separated list of endpoint=duration pairs, such as
+//
+//	GET /timeline=1m,POST /rating=5s
+type timeoutsFlag map[APIMapping]time.Duration
+
+func (tf timeoutsFlag) String() string {
+	var sb strings.Builder
+
+	var sep string
+	for m, d := range tf {
+		fmt.Fprintf(&sb, "%v%v %v=%v", sep, m.Method, m.Path, d)
+		sep = ","
+	}
+
+	return sb.String()
+}
+
+func (tf *timeoutsFlag) Set(val string) error {
+	if *tf == nil {
+		*tf = make(timeoutsFlag)
+	}
+
+	pairs := strings.Split(val, ",")
+	for _, pair := range pairs {
+		endpoint, timeout, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("%q is not valid", pair)
+		}
+		m, err := parseMapping(endpoint)
+		if err != nil {
+			return err
+		}
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return fmt.Errorf("parse %q: %w", timeout, err)
+		}
+
+		(*tf)[m] = d
+	}
+
+	return nil
+}
+
+// rateLimitsFlag is an implementation of flag.Value that reads a
+// comma-separated list of endpoint=limit pairs, such as
+//
+//	POST /comment=10/m,GET /timeline=5/s:20
+//
+// See ratelimit.ParseLimit for the format of the limits.
+type rateLimitsFlag map[APIMapping]ratelimit.Limit
+
+func (rf rateLimitsFlag) String() string {
+	var sb strings.Builder
+
+	var sep string
+	for m, l := range rf {
+		fmt.Fprintf(&sb, "%v%v %v=%v", sep, m.Method, m.Path, l)
+		sep = ","
+	}
+
+	return sb.String()
+}
+
+func (rf *rateLimitsFlag) Set(val string) error {
+	if *rf == nil {
+		*rf = make(rateLimitsFlag)
+	}
+
+	pairs := strings.Split(val, ",")
+	for _, pair := range pairs {
+		endpoint, limit, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("%q is not valid", pair)
+		}
+		m, err := parseMapping(endpoint)
+		if err != nil {
+			return err
+		}
+		l, err := ratelimit.ParseLimit(limit)
+		if err != nil {
+			return fmt.Errorf("parse %q: %w", limit, err)
+		}
+
+		(*rf)[m] = l
+	}
+
+	return nil
+}
+
+// parseMapping parses an endpoint of the form "METHOD /path".
+func parseMapping(endpoint string) (APIMapping, error) {
+	method, path, ok := strings.Cut(strings.TrimSpace(endpoint), " ")
+	if !ok {
+		return APIMapping{}, fmt.Errorf("%q is not a valid endpoint", endpoint)
+	}
+	return APIMapping{Method: strings.ToUpper(method), Path: path}, nil
+}
+
+// docFlag is an implementation of flag.Value that selects the format
+// to print documentation in. It can be used as a bool flag, in which
+// case it selects plain text.
+type docFlag string
+
+func (df docFlag) String() string {
+	return string(df)
+}
+
+func (df *docFlag) Set(val string) error {
+	switch val {
+	case "true", "text":
+		*df = "text"
+	case "openapi":
+		*df = "openapi"
+	case "false":
+		*df = ""
+	default:
+		return fmt.Errorf("unknown format %q", val)
+	}
+	return nil
+}
+
+func (df *docFlag) IsBoolFlag() bool {
+	return true
+}
+
 func printDoc(endpoints map[APIMapping]APIEndpoint) {
 	type sortable struct {
 		M APIMapping
@@ -54,22 +200,80 @@ func printDoc(endpoints map[APIMapping]APIEndpoint) {
 				desc = ": " + td
 			}
 
-			fmt.Printf("\t%v (%v)%v\n", name, f.Type, desc)
+			typ := f.Type.String()
+			if rules := f.Tag.Get("validate"); rules != "" {
+				typ += "; " + rules
+			}
+
+			fmt.Printf("\t%v (%v)%v\n", name, typ, desc)
 		}
 	}
 }
 
 func main() {
-	doc := flag.Bool("doc", false, "show API documentation instead of starting server")
-	addr := flag.String("addr", ":8080", "address to listen on")
-	dbaddr := flag.String("dbaddr", "localhost", "database address")
-	dbuser := flag.String("dbuser", "postgres", "database user")
-	dbpass := flag.String("dbpass", "", "database password")
-	dbname := flag.String("dbname", "bcc", "database name")
-	flag.Parse()
+	var doc docFlag
+	flag.Var(&doc, "doc", "show API documentation instead of starting server, optionally as `format` text or openapi")
+	var srvCfg config.Server
+	srvCfg.RegisterFlags(flag.CommandLine)
+	var dbCfg config.DB
+	dbCfg.RegisterFlags(flag.CommandLine)
+	timeout := flag.Duration("timeout", 30*time.Second, "maximum time to spend serving a request, 0 for no limit")
+	var timeouts timeoutsFlag
+	flag.Var(&timeouts, "endpoint-timeouts", "comma-separated list of per-endpoint timeouts, such as \"GET /timeline=1m\"")
+	cacheType := flag.String("cache", "", "where to cache query results: memory, the `URL` of a Redis server such as redis://localhost:6379/0, or empty to disable caching")
+	cacheTTL := flag.Duration("cache-ttl", time.Minute, "maximum time to cache query results for")
+	idempotencyWindow := flag.Duration("idempotency-window", 24*time.Hour, "how long to remember the responses to POST requests with an Idempotency-Key header, 0 to ignore the header")
+	idempotencyLease := flag.Duration("idempotency-lease", 5*time.Minute, "how long an Idempotency-Key stays claimed by a request that never finished, such as because the server stopped; should be longer than -timeout")
+	batchConcurrency := flag.Int("batch-concurrency", 4, "maximum number of requests from a single batch to serve at once")
+	var rateLimit ratelimit.Limit
+	flag.TextVar(&rateLimit, "rate-limit", ratelimit.Limit{}, "how often each client may make requests to each endpoint, such as 10/s or 100/m:20 for a burst of 20, or empty for no limit")
+	var rateLimits rateLimitsFlag
+	flag.Var(&rateLimits, "endpoint-rate-limits", "comma-separated list of per-endpoint rate limits, such as \"POST /comment=10/m\"")
+	rateLimitStore := flag.String("rate-limit-store", "memory", "where to keep rate limits: memory, or the `URL` of a Redis server such as redis://localhost:6379/0 to share them between servers")
+	trustedProxies := flag.String("trusted-proxies", "", "comma-separated list of networks, such as 10.0.0.0/8, of proxies whose X-Forwarded-For headers identify clients for rate limiting and idempotency keys")
+	accessLog := flag.Bool("access-log", true, "log every request; database queries are logged at the debug level")
+	defaultVersion := flag.Int("api-version", 1, "`version` of the API to serve requests that don't ask for one")
+	v1 := APIVersion{Number: 1}
+	flag.TextVar(&v1.Deprecated, "v1-deprecated", time.Time{}, "RFC 3339 `time` at which version 1 of the API was deprecated, if it has been")
+	flag.TextVar(&v1.Sunset, "v1-sunset", time.Time{}, "RFC 3339 `time` after which version 1 of the API will no longer be served, if it is known")
+	flag.StringVar(&v1.Link, "v1-deprecation-link", "", "`URL` of documentation about the deprecation of version 1 of the API")
+	var logOpts logging.Options
+	logOpts.RegisterFlags(flag.CommandLine)
+	corsOrigins := flag.String("cors", "", "comma-separated list of origins to allow cross-origin requests from, or * for any")
+	ratingCooldown := flag.Duration("rating-cooldown", 0, "minimum time between ratings of the same user by the same rater")
+	ratingDailyCap := flag.Int("rating-daily-cap", 0, "maximum number of ratings a user may give per day, 0 for no limit")
+	ratingMinAge := flag.Duration("rating-min-age", 0, "minimum account age required to rate other users")
+	ratingRequireComment := flag.Bool("rating-require-comment", false, "only allow rating users whose posts the rater has commented on")
+	err := config.Load(flag.CommandLine, os.Args[1:], &srvCfg, &dbCfg, &logOpts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		os.Exit(2)
+	}
+	logOpts.Setup()
+
+	versions := []APIVersion{v1, {Number: 2}}
+	if !slices.ContainsFunc(versions, func(v APIVersion) bool { return v.Number == *defaultVersion }) {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: unknown API version %v\n", *defaultVersion)
+		os.Exit(2)
+	}
+
+	ratingPolicy := bcc.RatingPolicy{
+		Cooldown:       *ratingCooldown,
+		DailyCap:       *ratingDailyCap,
+		MinAccountAge:  *ratingMinAge,
+		RequireComment: *ratingRequireComment,
+	}
+
+	graphQLSchema := newGraphQLSchema()
+	batch := &BatchHandler{Concurrency: *batchConcurrency}
 
 	endpoints := map[APIMapping]APIEndpoint{
-		{"GET", "/timeline"}: GetTimelineHandler{},
+		{"GET", "/timeline"}: VersionedEndpoint{
+			1: GetTimelineHandler{},
+			2: TransformResponse(GetTimelineHandler{}, timelineV2, TimelineResponseV2{}),
+		},
+
+		{"GET", "/leaderboard"}: GetLeaderboardHandler{},
 
 		{"GET", "/post"}:  GetPostHandler{},
 		{"POST", "/post"}: PostPostHandler{},
@@ -77,33 +281,261 @@ func main() {
 		{"POST", "/comment"}:   PostCommentHandler{},
 		{"DELETE", "/comment"}: DeleteCommentHandler{},
 
-		{"POST", "/rating"}: PostRatingHandler{},
+		{"POST", "/rating"}: PostRatingHandler{Policy: ratingPolicy},
+
+		{"GET", "/graphql"}:  GetGraphQLHandler{Schema: graphQLSchema},
+		{"POST", "/graphql"}: PostGraphQLHandler{Schema: graphQLSchema},
+
+		{"POST", "/batch"}: batch,
 	}
 
-	if *doc {
-		printDoc(endpoints)
+	docs := docEndpoints(endpoints, *defaultVersion)
+	endpoints[APIMapping{"GET", "/openapi.json"}] = OpenAPIHandler{Endpoints: docs}
+	docs[APIMapping{"GET", "/openapi.json"}] = endpoints[APIMapping{"GET", "/openapi.json"}]
+
+	switch doc {
+	case "text":
+		printDoc(docs)
+		return
+
+	case "openapi":
+		doc, err := openAPI(docs)
+		if err != nil {
+			logging.Fatal("Failed to generate OpenAPI document", "error", err)
+		}
+
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		err = e.Encode(doc)
+		if err != nil {
+			logging.Fatal("Failed to encode OpenAPI document", "error", err)
+		}
 		return
 	}
 
-	db, err := sqlx.Open("postgres", fmt.Sprintf(
-		"postgres://%v:%v@%v/%v?sslmode=disable",
-		*dbuser,
-		*dbpass,
-		*dbaddr,
-		*dbname,
-	))
+	db, err := dbCfg.Open()
+	if err != nil {
+		logging.Fatal("Failed to open database connection", "error", err)
+	}
+	replicas, err := dbCfg.OpenReplicas()
 	if err != nil {
-		log.Fatalf("Failed to open database connection: %v", err)
+		logging.Fatal("Failed to open replica database connection", "error", err)
+	}
+
+	replicaDBs := make([]bcc.Beginner, 0, len(replicas))
+	for _, r := range replicas {
+		replicaDBs = append(replicaDBs, bcc.Wrap(r))
+	}
+	var mdb bcc.Beginner = bcc.NewRouter(bcc.Wrap(db), replicaDBs...)
+	mdb = bcc.LogQueries(mdb, logQuery)
+	switch *cacheType {
+	case "":
+	case "memory":
+		mdb = bcc.WithCache(mdb, new(bcc.MemoryCache), *cacheTTL)
+	default:
+		c, err := rediscache.New(*cacheType, "bcc:")
+		if err != nil {
+			logging.Fatal("Failed to set up cache", "cache", *cacheType, "error", err)
+		}
+		defer c.Close()
+		mdb = bcc.WithCache(mdb, c, *cacheTTL)
+	}
+
+	middleware := []Middleware{Timing, Compress, Consistency}
+	if *accessLog {
+		middleware = append([]Middleware{AccessLog}, middleware...)
+	}
+	if *corsOrigins != "" {
+		middleware = append(middleware, CORS(strings.Split(*corsOrigins, ",")...))
+	}
+
+	var clients Clients
+	for _, p := range strings.Split(*trustedProxies, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(p)
+		if err != nil {
+			logging.Fatal("Invalid trusted proxy network", "network", p, "error", err)
+		}
+		clients.TrustedProxies = append(clients.TrustedProxies, prefix)
+	}
+
+	var rateLimiter *RateLimiter
+	if !rateLimit.IsZero() || (len(rateLimits) != 0) {
+		rateLimiter = &RateLimiter{Limit: rateLimit, Limits: rateLimits, Clients: clients}
+		switch *rateLimitStore {
+		case "memory":
+			rateLimiter.Store = new(ratelimit.Memory)
+		default:
+			r, err := ratelimit.NewRedis(*rateLimitStore, "bcc:ratelimit:")
+			if err != nil {
+				logging.Fatal("Failed to set up rate limit store", "store", *rateLimitStore, "error", err)
+			}
+			defer r.Close()
+			rateLimiter.Store = r
+		}
+	}
+
+	var idempotency *Idempotency
+	if *idempotencyWindow > 0 {
+		idempotency = &Idempotency{
+			DB:      mdb,
+			Window:  *idempotencyWindow,
+			Lease:   *idempotencyLease,
+			Clients: clients,
+		}
 	}
-	defer db.Close()
 
 	mux := &APIMux{
-		DB: db,
+		DB: mdb,
 
 		Endpoints: endpoints,
+
+		Versions:       versions,
+		DefaultVersion: *defaultVersion,
+
+		Timeout:  *timeout,
+		Timeouts: timeouts,
+
+		RateLimiter: rateLimiter,
+		Idempotency: idempotency,
+
+		Middleware: middleware,
+	}
+	batch.Mux = mux
+
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db.DB, "bcc"))
+	for i, r := range replicas {
+		prometheus.MustRegister(collectors.NewDBStatsCollector(r.DB, fmt.Sprintf("bcc-replica%v", i)))
+	}
+
+	health := &Health{DB: db, Replicas: replicas, Timeout: 5 * time.Second}
+
+	root := http.NewServeMux()
+	root.HandleFunc("GET /healthz", health.Live)
+	root.HandleFunc("GET /readyz", health.Ready)
+	root.Handle("/", mux)
+
+	metrics := root
+	if srvCfg.MetricsAddr != "" {
+		metrics = http.NewServeMux()
 	}
+	metrics.Handle("GET /metrics", promhttp.Handler())
 
-	log.Println("Starting server...")
-	err = http.ListenAndServe(*addr, mux)
-	log.Fatalf("Error starting server: %v", err)
+	errorLog := slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn)
+	servers := []*http.Server{srvCfg.HTTPServer(srvCfg.Addr, root)}
+	if srvCfg.MetricsAddr != "" {
+		servers = append(servers, srvCfg.HTTPServer(srvCfg.MetricsAddr, metrics))
+	}
+	for _, srv := range servers {
+		srv.ErrorLog = errorLog
+	}
+
+	var grpcServer *grpc.Server
+	if srvCfg.GRPCAddr != "" {
+		var opts []grpc.ServerOption
+		if srvCfg.TLSCert != "" {
+			creds, err := credentials.NewServerTLSFromFile(srvCfg.TLSCert, srvCfg.TLSKey)
+			if err != nil {
+				logging.Fatal("Failed to load TLS certificate", "error", err)
+			}
+			opts = append(opts, grpc.Creds(creds))
+		}
+
+		gs := &GRPCServer{
+			DB:           mdb,
+			Timeout:      *timeout,
+			AccessLog:    *accessLog,
+			RatingPolicy: ratingPolicy,
+			RateLimiter:  rateLimiter,
+		}
+		grpcServer = gs.NewServer(opts...)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *idempotencyWindow > 0 {
+		go expireIdempotencyKeys(ctx, mdb, *idempotencyWindow)
+	}
+
+	errc := make(chan error, len(servers)+1)
+	for _, srv := range servers {
+		go func() {
+			slog.Info("Starting server", "addr", srv.Addr)
+			errc <- srvCfg.ListenAndServe(srv)
+		}()
+	}
+	if grpcServer != nil {
+		go func() {
+			slog.Info("Starting gRPC server", "addr", srvCfg.GRPCAddr)
+			lis, err := net.Listen("tcp", srvCfg.GRPCAddr)
+			if err != nil {
+				errc <- err
+				return
+			}
+			errc <- grpcServer.Serve(lis)
+		}()
+	}
+
+	select {
+	case err := <-errc:
+		logging.Fatal("Error starting server", "error", err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	slog.Info("Shutting down", "timeout", srvCfg.ShutdownTimeout, "drain_delay", srvCfg.DrainDelay)
+	health.Drain()
+	time.Sleep(srvCfg.DrainDelay)
+
+	sctx, cancel := context.WithTimeout(context.Background(), srvCfg.ShutdownTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := srv.Shutdown(sctx)
+			if err != nil {
+				slog.Error("Failed to drain in-flight requests", "addr", srv.Addr, "error", err)
+				srv.Close()
+			}
+		}()
+	}
+	if grpcServer != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			stopped := make(chan struct{})
+			go func() {
+				grpcServer.GracefulStop()
+				close(stopped)
+			}()
+
+			select {
+			case <-stopped:
+			case <-sctx.Done():
+				slog.Error("Failed to drain in-flight calls", "addr", srvCfg.GRPCAddr, "error", sctx.Err())
+				grpcServer.Stop()
+			}
+		}()
+	}
+	wg.Wait()
+
+	err = db.Close()
+	if err != nil {
+		slog.Error("Failed to close database connection", "error", err)
+	}
+	for i, r := range replicas {
+		err := r.Close()
+		if err != nil {
+			slog.Error("Failed to close replica database connection", "replica", i, "error", err)
+		}
+	}
+	slog.Info("Stopped")
 }
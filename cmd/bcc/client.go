@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Clients identifies the clients that make requests, so that they can
+// be told apart by rate limiting and idempotency keys.
+type Clients struct {
+	// Key identifies the client making a request. If it is nil,
+	// clients are identified by their IP address, as the API doesn't
+	// authenticate requests yet.
+	Key func(req *http.Request) string
+
+	// TrustedProxies are the networks of proxies that are trusted to
+	// report the addresses of the clients that they forward requests
+	// for in an X-Forwarded-For header.
+	TrustedProxies []netip.Prefix
+}
+
+// clientKey returns the key that identifies the client making req.
+func (c Clients) clientKey(req *http.Request) string {
+	if c.Key != nil {
+		return c.Key(req)
+	}
+	return c.clientIP(req).String()
+}
+
+// clientIP returns the address of the client making req. If the
+// request was made by a trusted proxy, the address is the last one in
+// its X-Forwarded-For header that isn't also a trusted proxy.
+func (c Clients) clientIP(req *http.Request) netip.Addr {
+	addr := remoteAddr(req.RemoteAddr)
+
+	forwarded := strings.Split(strings.Join(req.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; (i >= 0) && c.trusted(addr); i-- {
+		next, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			break
+		}
+		addr = next.Unmap()
+	}
+	return addr
+}
+
+// remoteAddr returns the IP address in addr, which is either an IP
+// address or a host and port, such as those of http.Request.RemoteAddr.
+// If addr isn't one, it returns the zero Addr.
+func remoteAddr(addr string) netip.Addr {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return ip.Unmap()
+}
+
+// trusted returns true if addr belongs to a trusted proxy.
+func (c Clients) trusted(addr netip.Addr) bool {
+	for _, p := range c.TrustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,18 +1,16 @@
 package main
 
 import (
-	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/DeedleFake/backend-code-challenge/bcc"
-	"github.com/jmoiron/sqlx"
 )
 
 type PostCommentParams struct {
-	UserID  uint64 `json:"user_id" desc:"ID of the user making the comment"`
-	PostID  uint64 `json:"post_id" desc:"ID of the post on which a comment is being made"`
-	Message string `json:"message" desc:"contents of the comment"`
+	UserID  uint64 `json:"user_id" validate:"required" desc:"ID of the user making the comment" log:"user_id"`
+	PostID  uint64 `json:"post_id" validate:"required" desc:"ID of the post on which a comment is being made"`
+	Message string `json:"message" validate:"required" desc:"contents of the comment"`
 }
 
 type PostCommentHandler struct{}
@@ -25,13 +23,10 @@ func (h PostCommentHandler) Params() interface{} {
 	return &PostCommentParams{}
 }
 
-func (h PostCommentHandler) Serve(req *http.Request, db *sqlx.DB, params interface{}) (interface{}, error) {
+func (h PostCommentHandler) Serve(req *http.Request, db bcc.DB, params interface{}) (interface{}, error) {
 	q := params.(*PostCommentParams)
-	if q.Message == "" {
-		return nil, BadRequest(errors.New("message must not be blank"))
-	}
 
-	err := bcc.CreateComment(db, q.UserID, q.PostID, q.Message)
+	err := bcc.CreateComment(req.Context(), db, q.UserID, q.PostID, q.Message)
 	if err != nil {
 		return nil, fmt.Errorf("create comment: %w", err)
 	}
@@ -40,7 +35,7 @@ func (h PostCommentHandler) Serve(req *http.Request, db *sqlx.DB, params interfa
 }
 
 type DeleteCommentParams struct {
-	CommentID uint64 `query:"comment_id" desc:"ID of the comment being deleted"`
+	CommentID uint64 `query:"comment_id" validate:"required" desc:"ID of the comment being deleted"`
 }
 
 type DeleteCommentHandler struct{}
@@ -53,10 +48,10 @@ func (h DeleteCommentHandler) Params() interface{} {
 	return &DeleteCommentParams{}
 }
 
-func (h DeleteCommentHandler) Serve(req *http.Request, db *sqlx.DB, params interface{}) (interface{}, error) {
+func (h DeleteCommentHandler) Serve(req *http.Request, db bcc.DB, params interface{}) (interface{}, error) {
 	q := params.(*DeleteCommentParams)
 
-	err := bcc.DeleteComment(db, q.CommentID)
+	err := bcc.DeleteComment(req.Context(), db, q.CommentID)
 	if err != nil {
 		return nil, fmt.Errorf("delete comment: %w", err)
 	}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressMinSize is the smallest response that is worth compressing.
+// Anything smaller is likely to grow, or at least not shrink enough to
+// make up for the time spent.
+const compressMinSize = 1024
+
+// encoder is a compressing writer that can be reused.
+type encoder interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// encoding is a Content-Encoding that responses can be compressed
+// with. Encoders are expensive to create, so they are pooled.
+type encoding struct {
+	name string
+	pool *sync.Pool
+}
+
+func newEncoding(name string, newEncoder func() encoder) encoding {
+	return encoding{
+		name: name,
+		pool: &sync.Pool{New: func() any { return newEncoder() }},
+	}
+}
+
+// get returns an encoder that writes to w. It must be returned with
+// put once it has been closed.
+func (e encoding) get(w io.Writer) encoder {
+	enc := e.pool.Get().(encoder)
+	enc.Reset(w)
+	return enc
+}
+
+func (e encoding) put(enc encoder) {
+	enc.Reset(nil)
+	e.pool.Put(enc)
+}
+
+// encodings are the supported Content-Encodings in order of
+// preference.
+var encodings = []encoding{
+	newEncoding("zstd", func() encoder {
+		enc, err := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			panic(err)
+		}
+		return enc
+	}),
+	newEncoding("br", func() encoder {
+		return brotli.NewWriterLevel(nil, brotli.DefaultCompression)
+	}),
+	newEncoding("gzip", func() encoder {
+		return gzip.NewWriter(nil)
+	}),
+}
+
+// Compress is a Middleware that compresses responses with the best
+// encoding that the client accepts according to its Accept-Encoding
+// header. Responses that are too small to benefit, that have no body,
+// or that are already encoded are sent as is.
+//
+// Strong ETags are made weak in compressed responses, as the bytes
+// that are sent differ from those that the ETag was computed from.
+func Compress(next http.Handler) http.Handler {
+	names := make([]string, 0, len(encodings))
+	for _, e := range encodings {
+		names = append(names, e.name)
+	}
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Add("Vary", "Accept-Encoding")
+
+		name := negotiate(req.Header.Get("Accept-Encoding"), names, func(value, offer string) bool {
+			return (value == "*") || (value == offer)
+		})
+		if (name == "") || (req.Method == "HEAD") {
+			next.ServeHTTP(rw, req)
+			return
+		}
+
+		var enc encoding
+		for _, e := range encodings {
+			if e.name == name {
+				enc = e
+			}
+		}
+
+		cw := &compressWriter{ResponseWriter: rw, enc: enc}
+		defer cw.Close()
+		next.ServeHTTP(cw, req)
+	})
+}
+
+// compressWriter buffers the start of a response until it knows
+// whether or not the response is worth compressing.
+type compressWriter struct {
+	http.ResponseWriter
+	enc encoding
+
+	status  int
+	buf     bytes.Buffer
+	w       encoder
+	decided bool
+}
+
+func (rw *compressWriter) WriteHeader(status int) {
+	if rw.status != 0 {
+		return
+	}
+	rw.status = status
+
+	// Responses without bodies can't be compressed, so there's no
+	// reason to wait.
+	if (status < 200) || (status == http.StatusNoContent) || (status == http.StatusNotModified) {
+		rw.decide(false)
+	}
+}
+
+func (rw *compressWriter) Write(data []byte) (int, error) {
+	if rw.status == 0 {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if rw.decided {
+		if rw.w != nil {
+			return rw.w.Write(data)
+		}
+		return rw.ResponseWriter.Write(data)
+	}
+
+	rw.buf.Write(data)
+	if rw.buf.Len() >= compressMinSize {
+		err := rw.decide(rw.Header().Get("Content-Encoding") == "")
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// decide sends the headers, compressing the rest of the response if
+// compress is true, and then flushes the buffer.
+func (rw *compressWriter) decide(compress bool) error {
+	rw.decided = true
+
+	h := rw.Header()
+	if compress {
+		h.Set("Content-Encoding", rw.enc.name)
+		h.Del("Content-Length")
+		if etag := h.Get("ETag"); (etag != "") && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		rw.w = rw.enc.get(rw.ResponseWriter)
+	}
+	if rw.status != 0 {
+		rw.ResponseWriter.WriteHeader(rw.status)
+	}
+
+	if rw.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if rw.w != nil {
+		_, err = rw.w.Write(rw.buf.Bytes())
+	} else {
+		_, err = rw.ResponseWriter.Write(rw.buf.Bytes())
+	}
+	rw.buf.Reset()
+	return err
+}
+
+// Close finishes the response, sending anything still buffered.
+func (rw *compressWriter) Close() error {
+	if !rw.decided {
+		if rw.status == 0 {
+			return nil
+		}
+		err := rw.decide(false)
+		if err != nil {
+			return err
+		}
+	}
+	if rw.w != nil {
+		err := rw.w.Close()
+		rw.enc.put(rw.w)
+		rw.w = nil
+		return err
+	}
+	return nil
+}
+
+func (rw *compressWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
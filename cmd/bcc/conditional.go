@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// LastModifier is implemented by responses that know when the data in
+// them was last changed. It is used to set the Last-Modified header.
+type LastModifier interface {
+	LastModified() time.Time
+}
+
+// writeResponse sends rsp to the client encoded with c, which should
+// be the codec returned by codecFor for the request's Accept header.
+// Responses to GET requests are given an ETag and, if rsp implements
+// LastModifier, a Last-Modified header, and conditional requests that
+// they match are answered with 304 Not Modified. If etag is empty, the
+// ETag is computed from the response's contents.
+func writeResponse(rw http.ResponseWriter, req *http.Request, c codec, rsp interface{}, etag string) {
+	rw.Header().Set("Content-Type", c.contentType)
+	if req.Method != "GET" {
+		err := c.encode(rw, rsp)
+		if err != nil {
+			slog.WarnContext(req.Context(), "Failed to send response", "error", err)
+		}
+		return
+	}
+
+	var buf bytes.Buffer
+	err := c.encode(&buf, rsp)
+	if err != nil {
+		fail(rw, req, newProblem(http.StatusInternalServerError, "internal", "internal server error"), err)
+		return
+	}
+
+	if etag == "" {
+		sum := sha256.Sum256(buf.Bytes())
+		etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+	}
+
+	h := rw.Header()
+	h.Set("ETag", etag)
+	h.Set("Cache-Control", "no-cache")
+
+	var modified time.Time
+	if lm, ok := rsp.(LastModifier); ok {
+		modified = lm.LastModified().UTC().Truncate(time.Second)
+		if !modified.IsZero() {
+			h.Set("Last-Modified", modified.Format(http.TimeFormat))
+		}
+	}
+
+	if notModified(req, etag, modified) {
+		h.Del("Content-Type")
+		rw.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	_, err = buf.WriteTo(rw)
+	if err != nil {
+		slog.WarnContext(req.Context(), "Failed to send response", "error", err)
+	}
+}
+
+// validatorETag returns the ETag of a response encoded with c in the
+// given version of the API whose contents are identified by validator,
+// as returned by an APIValidator. Responses in different formats or
+// versions differ even if their contents don't, so they're included.
+func validatorETag(validator string, c codec, version int) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%v\x00%v\x00%v", validator, c.contentType, version)))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// notModified returns true if the conditional headers of req show that
+// the client already has the current version of a response. As
+// specified by RFC 9110, If-Modified-Since is ignored if
+// If-None-Match is present.
+func notModified(req *http.Request, etag string, modified time.Time) bool {
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if (tag == "*") || (tag == etag) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if modified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !modified.After(since)
+}
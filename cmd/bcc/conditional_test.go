@@ -0,0 +1,199 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DeedleFake/backend-code-challenge/bcc"
+)
+
+func TestNotModified(t *testing.T) {
+	const etag = `"abc"`
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		header   map[string]string
+		modified time.Time
+		want     bool
+	}{
+		{name: "NoConditions", modified: modified, want: false},
+		{name: "MatchingETag", header: map[string]string{"If-None-Match": `"abc"`}, want: true},
+		{name: "WeakETag", header: map[string]string{"If-None-Match": `W/"abc"`}, want: true},
+		{name: "ETagInList", header: map[string]string{"If-None-Match": `"xyz", "abc"`}, want: true},
+		{name: "Wildcard", header: map[string]string{"If-None-Match": `*`}, want: true},
+		{name: "DifferentETag", header: map[string]string{"If-None-Match": `"xyz"`}, want: false},
+		{
+			name:     "NotModifiedSince",
+			header:   map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)},
+			modified: modified,
+			want:     true,
+		},
+		{
+			name:     "ModifiedSince",
+			header:   map[string]string{"If-Modified-Since": modified.Add(-time.Second).Format(http.TimeFormat)},
+			modified: modified,
+			want:     false,
+		},
+		{
+			name:   "UnknownModificationTime",
+			header: map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)},
+			want:   false,
+		},
+		{
+			name:     "InvalidDate",
+			header:   map[string]string{"If-Modified-Since": "yesterday"},
+			modified: modified,
+			want:     false,
+		},
+		{
+			name: "IfNoneMatchWins",
+			header: map[string]string{
+				"If-None-Match":     `"xyz"`,
+				"If-Modified-Since": modified.Format(http.TimeFormat),
+			},
+			modified: modified,
+			want:     false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			for k, v := range test.header {
+				req.Header.Set(k, v)
+			}
+
+			if got := notModified(req, etag, test.modified); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+type lastModifiedResponse struct {
+	Value string `json:"value"`
+	time  time.Time
+}
+
+func (rsp lastModifiedResponse) LastModified() time.Time {
+	return rsp.time
+}
+
+func TestWriteResponseETag(t *testing.T) {
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 600, time.UTC)
+	rsp := lastModifiedResponse{Value: "a", time: modified}
+
+	write := func(method string, rsp interface{}, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		writeResponse(rec, req, codecs[0], rsp, "")
+		return rec
+	}
+
+	first := write("GET", rsp, nil)
+	etag := first.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag")
+	}
+	if lm := first.Header().Get("Last-Modified"); lm != modified.Format(http.TimeFormat) {
+		t.Errorf("Last-Modified is %q, want %q", lm, modified.Format(http.TimeFormat))
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		rsp    interface{}
+		header map[string]string
+		status int
+		etag   bool
+	}{
+		{name: "Same", method: "GET", rsp: rsp, status: http.StatusOK, etag: true},
+		{name: "Match", method: "GET", rsp: rsp, header: map[string]string{"If-None-Match": etag}, status: http.StatusNotModified, etag: true},
+		{name: "Changed", method: "GET", rsp: lastModifiedResponse{Value: "b", time: modified}, header: map[string]string{"If-None-Match": etag}, status: http.StatusOK},
+		{name: "NotGET", method: "POST", rsp: rsp, header: map[string]string{"If-None-Match": etag}, status: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := write(test.method, test.rsp, test.header)
+			if rec.Code != test.status {
+				t.Errorf("status is %v, want %v", rec.Code, test.status)
+			}
+			if got := rec.Header().Get("ETag") == etag; got != test.etag {
+				t.Errorf("ETag is %q, want it to be the same as the first: %v", rec.Header().Get("ETag"), test.etag)
+			}
+			if (rec.Code == http.StatusNotModified) && (rec.Body.Len() != 0) {
+				t.Errorf("304 response has a body: %q", rec.Body)
+			}
+		})
+	}
+}
+
+type validatorEndpoint struct {
+	testEndpoint
+	validator string
+	served    *int
+}
+
+func (h validatorEndpoint) Serve(req *http.Request, db bcc.DB, params interface{}) (interface{}, error) {
+	*h.served++
+	return h.testEndpoint.Serve(req, db, params)
+}
+
+func (h validatorEndpoint) Validator(req *http.Request, db bcc.DB, params interface{}) (string, bool) {
+	return h.validator, h.validator != ""
+}
+
+func TestValidatorETag(t *testing.T) {
+	tests := []struct {
+		name      string
+		validator string
+		inm       string
+		status    int
+		served    int
+	}{
+		{name: "NoCondition", validator: "v1", status: http.StatusOK, served: 1},
+		{name: "Match", validator: "v1", inm: validatorETag("v1", codecs[0], 1), status: http.StatusNotModified, served: 0},
+		{name: "Changed", validator: "v2", inm: validatorETag("v1", codecs[0], 1), status: http.StatusOK, served: 1},
+		{name: "OtherVersion", validator: "v1", inm: validatorETag("v1", codecs[0], 2), status: http.StatusOK, served: 1},
+		{name: "Unknown", inm: validatorETag("v1", codecs[0], 1), status: http.StatusOK, served: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var served int
+			mux := APIMux{
+				Endpoints: map[APIMapping]APIEndpoint{
+					{"GET", "/test"}: validatorEndpoint{validator: test.validator, served: &served},
+				},
+				Versions:       []APIVersion{{Number: 1}},
+				DefaultVersion: 1,
+			}
+
+			req := httptest.NewRequest("GET", "/test", nil)
+			if test.inm != "" {
+				req.Header.Set("If-None-Match", test.inm)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != test.status {
+				t.Errorf("status is %v, want %v", rec.Code, test.status)
+			}
+			if served != test.served {
+				t.Errorf("served %v times, want %v", served, test.served)
+			}
+			if test.validator != "" {
+				if etag := rec.Header().Get("ETag"); etag != validatorETag(test.validator, codecs[0], 1) {
+					t.Errorf("ETag is %q, want the validator's", etag)
+				}
+			}
+		})
+	}
+}
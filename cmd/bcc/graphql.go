@@ -0,0 +1,751 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/DeedleFake/backend-code-challenge/bcc"
+	"github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+)
+
+//go:embed schema.graphql
+var graphQLSchemaSource string
+
+// newGraphQLSchema parses the GraphQL schema and binds it to its
+// resolvers.
+func newGraphQLSchema() *graphql.Schema {
+	return graphql.MustParseSchema(
+		graphQLSchemaSource,
+		&gqlQuery{},
+		graphql.UseStringDescriptions(),
+		graphql.MaxDepth(10),
+		graphql.MaxParallelism(loaderMaxBatch),
+	)
+}
+
+type GetGraphQLParams struct {
+	Query         string `query:"query" validate:"required" desc:"GraphQL query to execute"`
+	OperationName string `query:"operationName" desc:"name of the operation in query to execute"`
+	Variables     string `query:"variables" desc:"JSON object holding the values of the query's variables"`
+}
+
+type PostGraphQLParams struct {
+	Query         string                 `json:"query" validate:"required" desc:"GraphQL query to execute"`
+	OperationName string                 `json:"operationName" desc:"name of the operation in query to execute"`
+	Variables     map[string]interface{} `json:"variables" desc:"values of the query's variables"`
+}
+
+// GraphQLResponse is the response to a GraphQL request. Errors in the
+// query itself are reported in Errors rather than as a Problem.
+type GraphQLResponse struct {
+	Data   interface{}             `json:"data"`
+	Errors []*gqlerrors.QueryError `json:"errors,omitempty"`
+}
+
+// GetGraphQLHandler serves GraphQL queries given in the URL. As with
+// other GET requests, they may be served from replicas and the cache.
+type GetGraphQLHandler struct {
+	Schema *graphql.Schema
+}
+
+func (h GetGraphQLHandler) Desc() string {
+	return "run a GraphQL query"
+}
+
+func (h GetGraphQLHandler) Params() interface{} {
+	return &GetGraphQLParams{}
+}
+
+func (h GetGraphQLHandler) Response() interface{} {
+	return GraphQLResponse{}
+}
+
+func (h GetGraphQLHandler) Serve(req *http.Request, db bcc.DB, params interface{}) (interface{}, error) {
+	q := params.(*GetGraphQLParams)
+
+	var vars map[string]interface{}
+	if q.Variables != "" {
+		err := json.Unmarshal([]byte(q.Variables), &vars)
+		if err != nil {
+			return nil, BadRequest(fmt.Errorf("variables: %w", err))
+		}
+	}
+
+	return execGraphQL(req.Context(), h.Schema, db, q.Query, q.OperationName, vars)
+}
+
+// PostGraphQLHandler serves GraphQL queries given in the request body.
+// Like GET requests, they may be served from replicas and the cache.
+type PostGraphQLHandler struct {
+	Schema *graphql.Schema
+}
+
+func (h PostGraphQLHandler) Desc() string {
+	return "run a GraphQL query"
+}
+
+func (h PostGraphQLHandler) Params() interface{} {
+	return &PostGraphQLParams{}
+}
+
+func (h PostGraphQLHandler) Response() interface{} {
+	return GraphQLResponse{}
+}
+
+func (h PostGraphQLHandler) Serve(req *http.Request, db bcc.DB, params interface{}) (interface{}, error) {
+	q := params.(*PostGraphQLParams)
+
+	// The schema has no mutations, so the request only reads, even
+	// though it is a POST.
+	return execGraphQL(readContext(req.Context()), h.Schema, db, q.Query, q.OperationName, q.Variables)
+}
+
+// execGraphQL runs a query against schema. The data in the result is
+// decoded so that it can be sent in any of the supported formats.
+func execGraphQL(ctx context.Context, schema *graphql.Schema, db bcc.DB, query, op string, vars map[string]interface{}) (interface{}, error) {
+	rsp := schema.Exec(withGQLContext(ctx, db), query, op, vars)
+
+	result := GraphQLResponse{Errors: rsp.Errors}
+	if len(rsp.Data) != 0 {
+		err := json.Unmarshal(rsp.Data, &result.Data)
+		if err != nil {
+			return nil, fmt.Errorf("decode data: %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+// gqlMaxNodes is the most nodes that a single GraphQL request can ask
+// for across all of the connections in it. Each connection counts as
+// many nodes as its first argument, whether or not that many exist.
+const gqlMaxNodes = 10000
+
+// gqlContext holds the state of a single GraphQL request. Its loaders
+// batch the lookups made by resolvers so that resolving a list doesn't
+// run a separate query for every item in it.
+type gqlContext struct {
+	db bcc.DB
+
+	users     *loader[uint64, bcc.User]
+	posts     *loader[uint64, bcc.Post]
+	comments  *loader[uint64, []bcc.Comment]
+	ratings   *loader[uint64, bcc.UserRating]
+	userPosts *loader[gqlPage, []bcc.Post]
+	timelines *loader[gqlPage, []bcc.TimelineEntry]
+
+	nodes atomic.Int64
+}
+
+// gqlPage identifies a page of a list that belongs to a user, such as
+// their posts.
+type gqlPage struct {
+	id           uint64
+	start, limit int
+}
+
+type gqlContextKey struct{}
+
+// withGQLContext returns a copy of ctx that carries a new gqlContext
+// that uses db.
+func withGQLContext(ctx context.Context, db bcc.DB) context.Context {
+	gc := &gqlContext{
+		db: db,
+		users: newLoader(ctx, func(ctx context.Context, ids []uint64) (map[uint64]bcc.User, error) {
+			return bcc.UsersByID(ctx, db, ids)
+		}),
+		posts: newLoader(ctx, func(ctx context.Context, ids []uint64) (map[uint64]bcc.Post, error) {
+			return bcc.PostsByID(ctx, db, ids)
+		}),
+		comments: newLoader(ctx, func(ctx context.Context, ids []uint64) (map[uint64][]bcc.Comment, error) {
+			return bcc.CommentsByPostIDs(ctx, db, ids)
+		}),
+		ratings: newLoader(ctx, func(ctx context.Context, ids []uint64) (map[uint64]bcc.UserRating, error) {
+			return bcc.RatingsByUserID(ctx, db, ids)
+		}),
+		userPosts: newLoader(ctx, func(ctx context.Context, pages []gqlPage) (map[gqlPage][]bcc.Post, error) {
+			return loadPages(ctx, pages, func(ctx context.Context, ids []uint64, start, limit int) (map[uint64][]bcc.Post, error) {
+				return bcc.PostsByUserIDs(ctx, db, ids, start, limit)
+			})
+		}),
+		timelines: newLoader(ctx, func(ctx context.Context, pages []gqlPage) (map[gqlPage][]bcc.TimelineEntry, error) {
+			return loadPages(ctx, pages, func(ctx context.Context, ids []uint64, start, limit int) (map[uint64][]bcc.TimelineEntry, error) {
+				return bcc.Timelines(ctx, db, ids, start, limit)
+			})
+		}),
+	}
+	return context.WithValue(ctx, gqlContextKey{}, gc)
+}
+
+// loadPages fetches pages with one call to fetch for each distinct
+// start and limit among them. Users' pages are usually all the same
+// size, so that is normally just one call.
+func loadPages[V any](ctx context.Context, pages []gqlPage, fetch func(ctx context.Context, ids []uint64, start, limit int) (map[uint64]V, error)) (map[gqlPage]V, error) {
+	type bounds struct{ start, limit int }
+	groups := make(map[bounds][]uint64)
+	for _, p := range pages {
+		b := bounds{start: p.start, limit: p.limit}
+		groups[b] = append(groups[b], p.id)
+	}
+
+	vals := make(map[gqlPage]V, len(pages))
+	for b, ids := range groups {
+		page, err := fetch(ctx, ids, b.start, b.limit)
+		if err != nil {
+			return nil, err
+		}
+		for id, v := range page {
+			vals[gqlPage{id: id, start: b.start, limit: b.limit}] = v
+		}
+	}
+	return vals, nil
+}
+
+func gqlContextFor(ctx context.Context) *gqlContext {
+	return ctx.Value(gqlContextKey{}).(*gqlContext)
+}
+
+// gqlError is an error that is safe to show to the client. Its code is
+// the same as that of the Problem that the REST API would send, and is
+// included in the error's extensions.
+type gqlError struct {
+	msg  string
+	code string
+}
+
+// gqlFail converts err into a gqlError, logging it if it's something
+// that the client didn't cause.
+func gqlFail(ctx context.Context, err error) error {
+	p := problemFor(ctx, err)
+	if p.Status >= http.StatusInternalServerError {
+		slog.ErrorContext(ctx, "Resolver failed", "error", err)
+	}
+	return gqlError{msg: p.Detail, code: p.Code}
+}
+
+func (err gqlError) Error() string {
+	return err.msg
+}
+
+func (err gqlError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": err.code}
+}
+
+func gqlID(id uint64) graphql.ID {
+	return graphql.ID(strconv.FormatUint(id, 10))
+}
+
+func parseGQLID(id graphql.ID) (uint64, error) {
+	v, err := strconv.ParseUint(string(id), 10, 64)
+	if err != nil {
+		return 0, gqlError{msg: fmt.Sprintf("invalid ID %q", id), code: "invalid"}
+	}
+	return v, nil
+}
+
+// loadUser loads a user that is expected to exist.
+func loadUser(ctx context.Context, id uint64) (*gqlUser, error) {
+	u, ok, err := gqlContextFor(ctx).users.Load(ctx, id)
+	if err != nil {
+		return nil, gqlFail(ctx, err)
+	}
+	if !ok {
+		return nil, gqlFail(ctx, &bcc.Error{Kind: bcc.ErrNotFound, Msg: fmt.Sprintf("user %v does not exist", id)})
+	}
+	return &gqlUser{u: u}, nil
+}
+
+// loadPost loads a post that is expected to exist.
+func loadPost(ctx context.Context, id uint64) (*gqlPost, error) {
+	p, ok, err := gqlContextFor(ctx).posts.Load(ctx, id)
+	if err != nil {
+		return nil, gqlFail(ctx, err)
+	}
+	if !ok {
+		return nil, gqlFail(ctx, &bcc.Error{Kind: bcc.ErrNotFound, Msg: fmt.Sprintf("post %v does not exist", id)})
+	}
+	return &gqlPost{p: p}, nil
+}
+
+// gqlPageArgs are the arguments of fields that return connections.
+type gqlPageArgs struct {
+	First int32
+	After *string
+}
+
+// bounds returns the offset of the first item of the page and the
+// number of items on it. The items are counted against the request's
+// limit of gqlMaxNodes.
+func (args gqlPageArgs) bounds(ctx context.Context) (start, limit int, err error) {
+	if (args.First < 0) || (args.First > 100) {
+		return 0, 0, gqlError{msg: "first must be between 0 and 100", code: "invalid"}
+	}
+	if gqlContextFor(ctx).nodes.Add(int64(args.First)) > gqlMaxNodes {
+		return 0, 0, gqlError{msg: fmt.Sprintf("query asks for more than %v nodes", gqlMaxNodes), code: "invalid"}
+	}
+
+	if args.After != nil {
+		start, err = parseCursor(*args.After)
+		if err != nil {
+			return 0, 0, err
+		}
+		start++
+	}
+
+	return start, int(args.First), nil
+}
+
+// cursor returns an opaque cursor for the item at offset i.
+func cursor(i int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("offset:" + strconv.FormatInt(int64(i), 10)))
+}
+
+func parseCursor(c string) (int, error) {
+	invalid := gqlError{msg: fmt.Sprintf("invalid cursor %q", c), code: "invalid"}
+
+	raw, err := base64.RawURLEncoding.DecodeString(c)
+	if err != nil {
+		return 0, invalid
+	}
+	offset, ok := strings.CutPrefix(string(raw), "offset:")
+	if !ok {
+		return 0, invalid
+	}
+	i, err := strconv.ParseUint(offset, 10, 31)
+	if err != nil {
+		return 0, invalid
+	}
+	return int(i), nil
+}
+
+// gqlConnection is a page of nodes. Fetching one more node than fits
+// on the page and passing it to newConnection lets it determine
+// whether there is a next page.
+type gqlConnection[T any] struct {
+	nodes []T
+	start int
+	more  bool
+}
+
+func newConnection[T any](nodes []T, start, limit int) *gqlConnection[T] {
+	more := len(nodes) > limit
+	if more {
+		nodes = nodes[:limit]
+	}
+	return &gqlConnection[T]{nodes: nodes, start: start, more: more}
+}
+
+func (c *gqlConnection[T]) Edges() []*gqlEdge[T] {
+	edges := make([]*gqlEdge[T], 0, len(c.nodes))
+	for i, n := range c.nodes {
+		edges = append(edges, &gqlEdge[T]{cursor: cursor(c.start + i), node: n})
+	}
+	return edges
+}
+
+func (c *gqlConnection[T]) PageInfo() *gqlPageInfo {
+	info := &gqlPageInfo{
+		hasNextPage:     c.more,
+		hasPreviousPage: c.start > 0,
+	}
+	if len(c.nodes) != 0 {
+		start, end := cursor(c.start), cursor(c.start+len(c.nodes)-1)
+		info.startCursor, info.endCursor = &start, &end
+	}
+	return info
+}
+
+type gqlEdge[T any] struct {
+	cursor string
+	node   T
+}
+
+func (e *gqlEdge[T]) Cursor() string {
+	return e.cursor
+}
+
+func (e *gqlEdge[T]) Node() T {
+	return e.node
+}
+
+type gqlPageInfo struct {
+	hasNextPage     bool
+	hasPreviousPage bool
+	startCursor     *string
+	endCursor       *string
+}
+
+func (info *gqlPageInfo) HasNextPage() bool {
+	return info.hasNextPage
+}
+
+func (info *gqlPageInfo) HasPreviousPage() bool {
+	return info.hasPreviousPage
+}
+
+func (info *gqlPageInfo) StartCursor() *string {
+	return info.startCursor
+}
+
+func (info *gqlPageInfo) EndCursor() *string {
+	return info.endCursor
+}
+
+// gqlQuery resolves the fields of the Query type.
+type gqlQuery struct{}
+
+func (*gqlQuery) User(ctx context.Context, args struct{ ID graphql.ID }) (*gqlUser, error) {
+	id, err := parseGQLID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	u, ok, err := gqlContextFor(ctx).users.Load(ctx, id)
+	if (err != nil) || !ok {
+		return nil, gqlFailIf(ctx, err)
+	}
+	return &gqlUser{u: u}, nil
+}
+
+func (*gqlQuery) Post(ctx context.Context, args struct{ ID graphql.ID }) (*gqlPost, error) {
+	id, err := parseGQLID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	p, ok, err := gqlContextFor(ctx).posts.Load(ctx, id)
+	if (err != nil) || !ok {
+		return nil, gqlFailIf(ctx, err)
+	}
+	return &gqlPost{p: p}, nil
+}
+
+func (*gqlQuery) Timeline(ctx context.Context, args struct {
+	UserID graphql.ID
+	First  int32
+	After  *string
+}) (*gqlConnection[*gqlTimelineEntry], error) {
+	id, err := parseGQLID(args.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return timelineConnection(ctx, id, gqlPageArgs{First: args.First, After: args.After})
+}
+
+// gqlFailIf is like gqlFail, but returns nil if err is nil.
+func gqlFailIf(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	return gqlFail(ctx, err)
+}
+
+// timelineConnection returns a page of a user's timeline.
+func timelineConnection(ctx context.Context, userID uint64, args gqlPageArgs) (*gqlConnection[*gqlTimelineEntry], error) {
+	start, limit, err := args.bounds(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, _, err := gqlContextFor(ctx).timelines.Load(ctx, gqlPage{id: userID, start: start, limit: limit + 1})
+	if err != nil {
+		return nil, gqlFail(ctx, fmt.Errorf("get timeline: %w", err))
+	}
+
+	nodes := make([]*gqlTimelineEntry, 0, len(entries))
+	for _, e := range entries {
+		entry, err := e.Typed()
+		if err != nil {
+			return nil, gqlFail(ctx, err)
+		}
+		nodes = append(nodes, newTimelineEntry(userID, entry))
+	}
+	return newConnection(nodes, start, limit), nil
+}
+
+// gqlUser resolves the fields of the User type.
+type gqlUser struct {
+	u bcc.User
+}
+
+func (u *gqlUser) ID() graphql.ID {
+	return gqlID(u.u.ID)
+}
+
+func (u *gqlUser) Name() string {
+	return u.u.Name
+}
+
+func (u *gqlUser) GitHubUsername() *string {
+	return u.u.GitHubUsername
+}
+
+func (u *gqlUser) RegisteredAt() graphql.Time {
+	return graphql.Time{Time: u.u.RegisteredAt}
+}
+
+func (u *gqlUser) Rating(ctx context.Context) (*gqlRating, error) {
+	r, ok, err := gqlContextFor(ctx).ratings.Load(ctx, u.u.ID)
+	if (err != nil) || !ok {
+		return nil, gqlFailIf(ctx, err)
+	}
+	return &gqlRating{r: r}, nil
+}
+
+func (u *gqlUser) Posts(ctx context.Context, args gqlPageArgs) (*gqlConnection[*gqlPost], error) {
+	start, limit, err := args.bounds(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	posts, _, err := gqlContextFor(ctx).userPosts.Load(ctx, gqlPage{id: u.u.ID, start: start, limit: limit + 1})
+	if err != nil {
+		return nil, gqlFail(ctx, fmt.Errorf("get posts: %w", err))
+	}
+
+	nodes := make([]*gqlPost, 0, len(posts))
+	for _, p := range posts {
+		nodes = append(nodes, &gqlPost{p: p})
+	}
+	return newConnection(nodes, start, limit), nil
+}
+
+func (u *gqlUser) Timeline(ctx context.Context, args gqlPageArgs) (*gqlConnection[*gqlTimelineEntry], error) {
+	return timelineConnection(ctx, u.u.ID, args)
+}
+
+// gqlRating resolves the fields of the Rating type.
+type gqlRating struct {
+	r bcc.UserRating
+}
+
+func (r *gqlRating) Value() float64 {
+	return r.r.Rating
+}
+
+func (r *gqlRating) RaterCount() int32 {
+	return int32(r.r.RaterCount)
+}
+
+func (r *gqlRating) UpdatedAt() graphql.Time {
+	return graphql.Time{Time: r.r.UpdatedAt}
+}
+
+// gqlPost resolves the fields of the Post type.
+type gqlPost struct {
+	p bcc.Post
+}
+
+func (p *gqlPost) ID() graphql.ID {
+	return gqlID(p.p.ID)
+}
+
+func (p *gqlPost) Author(ctx context.Context) (*gqlUser, error) {
+	return loadUser(ctx, p.p.UserID)
+}
+
+func (p *gqlPost) PostedAt() graphql.Time {
+	return graphql.Time{Time: p.p.PostedAt}
+}
+
+func (p *gqlPost) UpdatedAt() graphql.Time {
+	return graphql.Time{Time: p.p.UpdatedAt}
+}
+
+func (p *gqlPost) Title() string {
+	return p.p.Title
+}
+
+func (p *gqlPost) Body() string {
+	return p.p.Body
+}
+
+func (p *gqlPost) Comments(ctx context.Context, args gqlPageArgs) (*gqlConnection[*gqlComment], error) {
+	start, limit, err := args.bounds(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	comments, _, err := gqlContextFor(ctx).comments.Load(ctx, p.p.ID)
+	if err != nil {
+		return nil, gqlFail(ctx, fmt.Errorf("get comments: %w", err))
+	}
+
+	comments = comments[min(start, len(comments)):]
+	comments = comments[:min(limit+1, len(comments))]
+	nodes := make([]*gqlComment, 0, len(comments))
+	for _, c := range comments {
+		nodes = append(nodes, &gqlComment{c: c})
+	}
+	return newConnection(nodes, start, limit), nil
+}
+
+// gqlComment resolves the fields of the Comment type.
+type gqlComment struct {
+	c bcc.Comment
+}
+
+func (c *gqlComment) ID() graphql.ID {
+	return gqlID(c.c.ID)
+}
+
+func (c *gqlComment) Author(ctx context.Context) (*gqlUser, error) {
+	return loadUser(ctx, c.c.UserID)
+}
+
+func (c *gqlComment) Post(ctx context.Context) (*gqlPost, error) {
+	return loadPost(ctx, c.c.PostID)
+}
+
+func (c *gqlComment) PostedAt() graphql.Time {
+	return graphql.Time{Time: c.c.CommentedAt}
+}
+
+func (c *gqlComment) UpdatedAt() graphql.Time {
+	return graphql.Time{Time: c.c.UpdatedAt}
+}
+
+func (c *gqlComment) Message() string {
+	return c.c.Message
+}
+
+// gqlPassedRating resolves the fields of the PassedRating type.
+type gqlPassedRating struct {
+	userID uint64
+	e      *bcc.RatingMilestoneEntry
+}
+
+func (r *gqlPassedRating) ID() graphql.ID {
+	return gqlID(r.e.ID)
+}
+
+func (r *gqlPassedRating) User(ctx context.Context) (*gqlUser, error) {
+	return loadUser(ctx, r.userID)
+}
+
+func (r *gqlPassedRating) RatedAt() graphql.Time {
+	return graphql.Time{Time: r.e.PostedAt}
+}
+
+func (r *gqlPassedRating) Before() float64 {
+	return r.e.Before
+}
+
+func (r *gqlPassedRating) After() float64 {
+	return r.e.After
+}
+
+// gqlGitHubEvent resolves the fields of the GitHubEvent type.
+type gqlGitHubEvent struct {
+	userID uint64
+	e      *bcc.GitHubEntry
+}
+
+func (ev *gqlGitHubEvent) ID() graphql.ID {
+	return gqlID(ev.e.ID)
+}
+
+func (ev *gqlGitHubEvent) User(ctx context.Context) (*gqlUser, error) {
+	return loadUser(ctx, ev.userID)
+}
+
+func (ev *gqlGitHubEvent) CreatedAt() graphql.Time {
+	return graphql.Time{Time: ev.e.PostedAt}
+}
+
+func (ev *gqlGitHubEvent) Type() string {
+	return ev.e.EventType
+}
+
+func (ev *gqlGitHubEvent) Repo() string {
+	return ev.e.Repo
+}
+
+func (ev *gqlGitHubEvent) PullRequest() *int32 {
+	if ev.e.PR == nil {
+		return nil
+	}
+	pr := int32(*ev.e.PR)
+	return &pr
+}
+
+func (ev *gqlGitHubEvent) Commits() *int32 {
+	if ev.e.Commits == nil {
+		return nil
+	}
+	commits := int32(*ev.e.Commits)
+	return &commits
+}
+
+func (ev *gqlGitHubEvent) Head() *string {
+	return ev.e.Head
+}
+
+// gqlTimelineEntry resolves the TimelineEntry union. Exactly one of
+// its fields is set.
+type gqlTimelineEntry struct {
+	post         *gqlPost
+	comment      *gqlComment
+	passedRating *gqlPassedRating
+	githubEvent  *gqlGitHubEvent
+}
+
+// newTimelineEntry converts an entry in userID's timeline into the
+// type that resolves it.
+func newTimelineEntry(userID uint64, e bcc.Entry) *gqlTimelineEntry {
+	switch e := e.(type) {
+	case *bcc.PostEntry:
+		return &gqlTimelineEntry{post: &gqlPost{p: bcc.Post{
+			ID:        e.ID,
+			Title:     e.Title,
+			Body:      e.Body,
+			UserID:    userID,
+			PostedAt:  e.PostedAt,
+			UpdatedAt: e.UpdatedAt,
+		}}}
+
+	case *bcc.CommentEntry:
+		return &gqlTimelineEntry{comment: &gqlComment{c: bcc.Comment{
+			ID:          e.ID,
+			UserID:      userID,
+			PostID:      e.PostID,
+			Message:     e.Message,
+			CommentedAt: e.PostedAt,
+			UpdatedAt:   e.UpdatedAt,
+		}}}
+
+	case *bcc.RatingMilestoneEntry:
+		return &gqlTimelineEntry{passedRating: &gqlPassedRating{userID: userID, e: e}}
+
+	case *bcc.GitHubEntry:
+		return &gqlTimelineEntry{githubEvent: &gqlGitHubEvent{userID: userID, e: e}}
+
+	default:
+		panic(fmt.Errorf("unknown entry type %T", e))
+	}
+}
+
+func (e *gqlTimelineEntry) ToPost() (*gqlPost, bool) {
+	return e.post, e.post != nil
+}
+
+func (e *gqlTimelineEntry) ToComment() (*gqlComment, bool) {
+	return e.comment, e.comment != nil
+}
+
+func (e *gqlTimelineEntry) ToPassedRating() (*gqlPassedRating, bool) {
+	return e.passedRating, e.passedRating != nil
+}
+
+func (e *gqlTimelineEntry) ToGitHubEvent() (*gqlGitHubEvent, bool) {
+	return e.githubEvent, e.githubEvent != nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestLoadPages(t *testing.T) {
+	pages := []gqlPage{
+		{id: 1, start: 0, limit: 10},
+		{id: 2, start: 0, limit: 10},
+		{id: 3, start: 10, limit: 10},
+		{id: 4, start: 0, limit: 5},
+	}
+
+	var calls int
+	vals, err := loadPages(context.Background(), pages, func(ctx context.Context, ids []uint64, start, limit int) (map[uint64][]int, error) {
+		calls++
+		page := make(map[uint64][]int, len(ids))
+		for _, id := range ids {
+			if id != 2 {
+				page[id] = []int{int(id), start, limit}
+			}
+		}
+		return page, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 3 {
+		t.Errorf("fetch was called %v times, want 3", calls)
+	}
+	want := map[gqlPage][]int{
+		{id: 1, start: 0, limit: 10}:  {1, 0, 10},
+		{id: 3, start: 10, limit: 10}: {3, 10, 10},
+		{id: 4, start: 0, limit: 5}:   {4, 0, 5},
+	}
+	if !maps.EqualFunc(vals, want, slices.Equal) {
+		t.Errorf("got %v, want %v", vals, want)
+	}
+}
+
+func TestPageArgsNodeLimit(t *testing.T) {
+	ctx := withGQLContext(context.Background(), nil)
+
+	for i := 0; i < gqlMaxNodes/100; i++ {
+		_, _, err := gqlPageArgs{First: 100}.bounds(ctx)
+		if err != nil {
+			t.Fatalf("page %v: %v", i, err)
+		}
+	}
+	_, _, err := gqlPageArgs{First: 1}.bounds(ctx)
+	if err == nil {
+		t.Fatal("no error after using up the node limit")
+	}
+}
@@ -0,0 +1,463 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/DeedleFake/backend-code-challenge/bcc"
+	"github.com/DeedleFake/backend-code-challenge/bcc/bccpb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// GRPCServer implements the gRPC services in package bccpb. Each call
+// is served the same way as the REST endpoint that it mirrors.
+type GRPCServer struct {
+	bccpb.UnimplementedTimelineServiceServer
+	bccpb.UnimplementedPostServiceServer
+	bccpb.UnimplementedCommentServiceServer
+	bccpb.UnimplementedRatingServiceServer
+
+	// DB is the database connection to serve calls with.
+	DB bcc.Beginner
+
+	// Timeout is the maximum amount of time that a call is given to
+	// finish, in addition to any deadline set by the client. Zero means
+	// no limit.
+	Timeout time.Duration
+
+	// AccessLog, if true, logs every call like the AccessLog
+	// Middleware does for REST requests.
+	AccessLog bool
+
+	// RatingPolicy is the set of anti-abuse rules that ratings are
+	// checked against.
+	RatingPolicy bcc.RatingPolicy
+
+	// RateLimiter, if not nil, limits how often clients can make
+	// calls. Calls are limited as requests to the REST endpoints that
+	// they mirror and share their buckets, so clients can't get around
+	// the limits by switching APIs. Clients are identified by their
+	// IP address.
+	RateLimiter *RateLimiter
+}
+
+// NewServer returns a gRPC server with s's services registered on it.
+func (s *GRPCServer) NewServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(
+		opts,
+		grpc.ChainUnaryInterceptor(s.unary),
+		grpc.ChainStreamInterceptor(s.stream),
+	)
+
+	srv := grpc.NewServer(opts...)
+	bccpb.RegisterTimelineServiceServer(srv, s)
+	bccpb.RegisterPostServiceServer(srv, s)
+	bccpb.RegisterCommentServiceServer(srv, s)
+	bccpb.RegisterRatingServiceServer(srv, s)
+	return srv
+}
+
+// grpcReadMethods are the methods that only read from the database.
+// Like GET requests, they may be served from a replica.
+var grpcReadMethods = map[string]bool{
+	bccpb.TimelineService_GetTimeline_FullMethodName: true,
+	bccpb.PostService_GetPost_FullMethodName:         true,
+}
+
+// grpcEndpoints maps methods to the REST endpoints that they mirror.
+var grpcEndpoints = map[string]APIMapping{
+	bccpb.TimelineService_GetTimeline_FullMethodName:  {"GET", "/timeline"},
+	bccpb.PostService_GetPost_FullMethodName:          {"GET", "/post"},
+	bccpb.PostService_CreatePost_FullMethodName:       {"POST", "/post"},
+	bccpb.CommentService_CreateComment_FullMethodName: {"POST", "/comment"},
+	bccpb.CommentService_DeleteComment_FullMethodName: {"DELETE", "/comment"},
+	bccpb.RatingService_RateUser_FullMethodName:       {"POST", "/rating"},
+}
+
+// grpcCodes maps the codes of Problems to gRPC status codes. Codes
+// that aren't listed map to codes.Internal.
+var grpcCodes = map[string]codes.Code{
+	"bad_request":       codes.InvalidArgument,
+	"validation_failed": codes.InvalidArgument,
+	"invalid":           codes.InvalidArgument,
+	"not_found":         codes.NotFound,
+	"conflict":          codes.AlreadyExists,
+	"forbidden":         codes.PermissionDenied,
+	"rate_limited":      codes.ResourceExhausted,
+	"timeout":           codes.DeadlineExceeded,
+	"canceled":          codes.Canceled,
+}
+
+// problemStatus converts p into a gRPC status. The status carries p's
+// code in an ErrorInfo, the ID of the call in a RequestInfo, and, if
+// there are any, p's field errors in a BadRequest.
+func problemStatus(ctx context.Context, p Problem) *status.Status {
+	code, ok := grpcCodes[p.Code]
+	if !ok {
+		code = codes.Internal
+	}
+
+	st, err := status.New(code, p.Detail).WithDetails(
+		&errdetails.ErrorInfo{Reason: p.Code, Domain: "bcc"},
+		&errdetails.RequestInfo{RequestId: bcc.RequestID(ctx)},
+	)
+	if err != nil {
+		return status.New(code, p.Detail)
+	}
+
+	if len(p.Errors) != 0 {
+		br := &errdetails.BadRequest{
+			FieldViolations: make([]*errdetails.BadRequest_FieldViolation, 0, len(p.Errors)),
+		}
+		for _, fe := range p.Errors {
+			br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       fe.Field,
+				Description: fe.Message,
+			})
+		}
+		if withBR, err := st.WithDetails(br); err == nil {
+			st = withBR
+		}
+	}
+
+	return st
+}
+
+// checkParams validates params the same way that APIMux does and
+// adds their log attributes to the call's access log entry.
+func checkParams(ctx context.Context, params interface{}, tag string) error {
+	addLogAttrs(ctx, paramLogAttrs(params)...)
+
+	fieldErrs, err := validate(params, tag)
+	if err != nil {
+		return fmt.Errorf("validate: %w", err)
+	}
+	if len(fieldErrs) != 0 {
+		p := newProblem(http.StatusUnprocessableEntity, "validation_failed", "invalid parameters")
+		p.Errors = fieldErrs
+		return problemStatus(ctx, p).Err()
+	}
+
+	return nil
+}
+
+// begin sets up ctx for a call to method. It returns the context to
+// serve the call with and a function to call with the result of a
+// recover and the call's error when the call is done. That function
+// converts the error into a gRPC status, logs it, and records the call
+// in the metrics.
+func (s *GRPCServer) begin(ctx context.Context, method string) (context.Context, func(r any, err error) error) {
+	start := time.Now()
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	first := func(key string) string {
+		if v := md.Get(key); len(v) != 0 {
+			return v[0]
+		}
+		return ""
+	}
+
+	id := first("x-request-id")
+	if !validRequestID(id) {
+		id = newRequestID()
+	}
+	ctx = bcc.WithRequestID(ctx, id)
+	grpc.SetHeader(ctx, metadata.Pairs("x-request-id", id))
+
+	if !grpcReadMethods[method] || strings.EqualFold(first("x-consistency"), "strong") {
+		ctx = bcc.WithPrimary(ctx)
+	}
+
+	var rl *requestLog
+	if s.AccessLog {
+		ctx, rl = withRequestLog(ctx)
+	}
+
+	cancel := func() {}
+	if s.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+	}
+
+	return ctx, func(r any, err error) error {
+		defer cancel()
+
+		if r != nil {
+			slog.ErrorContext(ctx, "Panic", "panic", r, "stack", string(debug.Stack()))
+			err = problemStatus(ctx, newProblem(http.StatusInternalServerError, "internal", "internal server error")).Err()
+		}
+		if _, ok := status.FromError(err); !ok {
+			p := problemFor(ctx, err)
+			slog.ErrorContext(ctx, "Call failed", "method", method, "error", err)
+			err = problemStatus(ctx, p).Err()
+		}
+
+		code := status.Code(err)
+		grpcRequestsTotal.WithLabelValues(method, code.String()).Inc()
+		grpcRequestDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+
+		if rl != nil {
+			rl.m.Lock()
+			defer rl.m.Unlock()
+
+			attrs := append([]slog.Attr{
+				slog.String("method", method),
+				slog.String("status", code.String()),
+				slog.Duration("latency", time.Since(start)),
+				slog.Int("sql_queries", rl.queries),
+				slog.Duration("sql_time", rl.queryTime),
+			}, rl.attrs...)
+			slog.LogAttrs(ctx, slog.LevelInfo, "Call", attrs...)
+		}
+
+		return err
+	}
+}
+
+// limit takes a token for the call to method from s's RateLimiter. It
+// sends the RateLimit headers as metadata and, if there wasn't a token
+// to take, returns a ResourceExhausted status that says when to retry.
+func (s *GRPCServer) limit(ctx context.Context, method string) error {
+	mapping, ok := grpcEndpoints[method]
+	if (s.RateLimiter == nil) || !ok {
+		return nil
+	}
+
+	var client string
+	if p, ok := peer.FromContext(ctx); ok {
+		client = remoteAddr(p.Addr.String()).String()
+	}
+	limit, r, ok := s.RateLimiter.take(ctx, mapping, client)
+	if !ok {
+		return nil
+	}
+
+	h := make(http.Header)
+	setRateLimitHeaders(h, limit, r)
+	md := make(metadata.MD, len(h))
+	for k, v := range h {
+		md.Append(k, v...)
+	}
+	grpc.SetHeader(ctx, md)
+	if r.Allowed {
+		return nil
+	}
+
+	addLogAttrs(ctx, slog.String("client", client))
+	st := problemStatus(ctx, newProblem(http.StatusTooManyRequests, "rate_limited", "too many requests"))
+	if withRetry, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(r.RetryAfter)}); err == nil {
+		st = withRetry
+	}
+	return st.Err()
+}
+
+func (s *GRPCServer) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (rsp any, err error) {
+	ctx, done := s.begin(ctx, info.FullMethod)
+	defer func() { err = done(recover(), err) }()
+
+	err = s.limit(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (s *GRPCServer) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	ctx, done := s.begin(ss.Context(), info.FullMethod)
+	defer func() { err = done(recover(), err) }()
+
+	err = s.limit(ctx, info.FullMethod)
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, contextStream{ServerStream: ss, ctx: ctx})
+}
+
+// contextStream is a grpc.ServerStream with a different context.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (ss contextStream) Context() context.Context {
+	return ss.ctx
+}
+
+func (s *GRPCServer) GetTimeline(req *bccpb.GetTimelineRequest, stream grpc.ServerStreamingServer[bccpb.GetTimelineResponse]) error {
+	ctx := stream.Context()
+
+	q := GetTimelineParams{
+		UserID: req.UserId,
+		Start:  int(req.Start),
+		Limit:  int(req.Limit),
+	}
+	if q.Limit == 0 {
+		q.Limit = 10
+	}
+	err := checkParams(ctx, &q, "query")
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	defer func() { timelineDuration.Observe(time.Since(start).Seconds()) }()
+
+	entries, err := bcc.Timeline(ctx, s.DB, q.UserID, q.Start, q.Limit)
+	if err != nil {
+		return fmt.Errorf("get timeline: %w", err)
+	}
+
+	for entry, err := range entries.All() {
+		if err != nil {
+			return fmt.Errorf("iteration: %w", err)
+		}
+
+		err = stream.Send(&bccpb.GetTimelineResponse{Entry: timelineEntryProto(entry)})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// timelineEntryProto converts entry into its protobuf equivalent.
+func timelineEntryProto(entry bcc.TimelineEntry) *bccpb.TimelineEntry {
+	pb := &bccpb.TimelineEntry{
+		Type: entry.Type,
+
+		PostedAt:  timestamppb.New(entry.PostedAt),
+		UpdatedAt: timestamppb.New(entry.UpdatedAt),
+		Id:        entry.ID,
+
+		Title: entry.Title,
+		Body:  entry.Body,
+
+		PostId:         entry.PostID,
+		Message:        entry.Message,
+		PostUserId:     entry.PostUserID,
+		PostUserName:   entry.PostUserName,
+		PostUserRating: entry.PostUserRating,
+
+		PassedRatingBefore: entry.PassedRatingBefore,
+		PassedRatingAfter:  entry.PassedRatingAfter,
+
+		GithubEventType: entry.GitHubEventType,
+		GithubEventRepo: entry.GitHubEventRepo,
+		GithubEventPr:   entry.GitHubEventPR,
+		GithubEventHead: entry.GitHubEventHead,
+	}
+	if entry.GitHubEventCommits != nil {
+		commits := int64(*entry.GitHubEventCommits)
+		pb.GithubEventCommits = &commits
+	}
+	return pb
+}
+
+func (s *GRPCServer) GetPost(ctx context.Context, req *bccpb.GetPostRequest) (*bccpb.GetPostResponse, error) {
+	q := GetPostParams{PostID: req.PostId}
+	err := checkParams(ctx, &q, "query")
+	if err != nil {
+		return nil, err
+	}
+
+	post, comments, err := bcc.PostWithComments(ctx, s.DB, q.PostID)
+	if err != nil {
+		return nil, fmt.Errorf("post: %w", err)
+	}
+
+	rsp := &bccpb.GetPostResponse{
+		UserId:    post.UserID,
+		PostedAt:  timestamppb.New(post.PostedAt),
+		UpdatedAt: timestamppb.New(post.UpdatedAt),
+
+		Title:    post.Title,
+		Body:     post.Body,
+		Comments: make([]*bccpb.Comment, 0, len(comments)),
+	}
+
+	for _, comment := range comments {
+		rsp.Comments = append(rsp.Comments, &bccpb.Comment{
+			UserId:    comment.UserID,
+			PostedAt:  timestamppb.New(comment.CommentedAt),
+			UpdatedAt: timestamppb.New(comment.UpdatedAt),
+			Id:        comment.ID,
+			Message:   comment.Message,
+		})
+	}
+
+	return rsp, nil
+}
+
+func (s *GRPCServer) CreatePost(ctx context.Context, req *bccpb.CreatePostRequest) (*bccpb.CreatePostResponse, error) {
+	q := PostPostParams{UserID: req.UserId, Title: req.Title, Body: req.Body}
+	err := checkParams(ctx, &q, "json")
+	if err != nil {
+		return nil, err
+	}
+
+	err = bcc.CreatePost(ctx, s.DB, q.UserID, q.Title, q.Body)
+	if err != nil {
+		return nil, fmt.Errorf("create post: %w", err)
+	}
+
+	return &bccpb.CreatePostResponse{}, nil
+}
+
+func (s *GRPCServer) CreateComment(ctx context.Context, req *bccpb.CreateCommentRequest) (*bccpb.CreateCommentResponse, error) {
+	q := PostCommentParams{UserID: req.UserId, PostID: req.PostId, Message: req.Message}
+	err := checkParams(ctx, &q, "json")
+	if err != nil {
+		return nil, err
+	}
+
+	err = bcc.CreateComment(ctx, s.DB, q.UserID, q.PostID, q.Message)
+	if err != nil {
+		return nil, fmt.Errorf("create comment: %w", err)
+	}
+
+	return &bccpb.CreateCommentResponse{}, nil
+}
+
+func (s *GRPCServer) DeleteComment(ctx context.Context, req *bccpb.DeleteCommentRequest) (*bccpb.DeleteCommentResponse, error) {
+	q := DeleteCommentParams{CommentID: req.CommentId}
+	err := checkParams(ctx, &q, "query")
+	if err != nil {
+		return nil, err
+	}
+
+	err = bcc.DeleteComment(ctx, s.DB, q.CommentID)
+	if err != nil {
+		return nil, fmt.Errorf("delete comment: %w", err)
+	}
+
+	return &bccpb.DeleteCommentResponse{}, nil
+}
+
+func (s *GRPCServer) RateUser(ctx context.Context, req *bccpb.RateUserRequest) (*bccpb.RateUserResponse, error) {
+	q := PostRatingParams{UserID: req.UserId, RaterID: req.RaterId, Rating: req.Rating}
+	err := checkParams(ctx, &q, "json")
+	if err != nil {
+		return nil, err
+	}
+
+	err = rateUser(ctx, s.DB, s.RatingPolicy, q.RaterID, q.UserID, q.Rating)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bccpb.RateUserResponse{}, nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DeedleFake/backend-code-challenge/bcc"
+	"github.com/jmoiron/sqlx"
+)
+
+// Health serves liveness and readiness checks for load balancers and
+// orchestrators. They are kept separate from the API's endpoints so
+// that they are cheap and aren't affected by its middleware.
+type Health struct {
+	// DB is checked by the readiness check.
+	DB *sqlx.DB
+
+	// Replicas are pinged by the readiness check. Ones that can't be
+	// reached are reported, but the check only fails if none of them
+	// can be, as reads can still be served by the rest.
+	Replicas []*sqlx.DB
+
+	// Timeout limits how long the readiness check waits on the
+	// database.
+	Timeout time.Duration
+
+	draining atomic.Bool
+}
+
+// Drain causes all future readiness checks to fail so that traffic is
+// directed elsewhere while the server shuts down.
+func (h *Health) Drain() {
+	h.draining.Store(true)
+}
+
+var (
+	errDraining = errors.New("shutting down")
+	errDatabase = errors.New("database unavailable")
+	errSchema   = errors.New("database schema check failed")
+	errReplicas = errors.New("no database replicas available")
+)
+
+// healthReason returns the reason to give clients for a failed check.
+// Errors from the database can include details about it, such as its
+// address, so they are logged rather than being sent.
+func healthReason(err error) string {
+	for _, reason := range []error{errDraining, errDatabase, errSchema, errReplicas} {
+		if errors.Is(err, reason) {
+			return reason.Error()
+		}
+	}
+	return "unavailable"
+}
+
+type healthResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+
+	// ReplicasDown lists the indices of the replicas that couldn't be
+	// reached, in the order in which they were configured.
+	ReplicasDown []int `json:"replicas_down,omitempty"`
+}
+
+func (h *Health) write(rw http.ResponseWriter, req *http.Request, replicasDown []int, err error) {
+	rsp := healthResponse{Status: "ok", ReplicasDown: replicasDown}
+	status := http.StatusOK
+	if err != nil {
+		rsp = healthResponse{Status: "unavailable", Error: healthReason(err), ReplicasDown: replicasDown}
+		status = http.StatusServiceUnavailable
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.WriteHeader(status)
+
+	err = json.NewEncoder(rw).Encode(rsp)
+	if err != nil {
+		slog.WarnContext(req.Context(), "Failed to send health check", "error", err)
+	}
+}
+
+// Live reports that the process is running and able to serve
+// requests.
+func (h *Health) Live(rw http.ResponseWriter, req *http.Request) {
+	h.write(rw, req, nil, nil)
+}
+
+// Ready reports whether the server can currently do useful work: it
+// isn't shutting down, the database and at least one of its replicas,
+// if it has any, are reachable, and the database's schema is at the
+// expected version. Replicas that can't be reached are listed in the
+// response either way.
+func (h *Health) Ready(rw http.ResponseWriter, req *http.Request) {
+	replicasDown, err := h.ready(req.Context())
+	if err != nil {
+		slog.WarnContext(req.Context(), "Readiness check failed", "error", err)
+	}
+	h.write(rw, req, replicasDown, err)
+}
+
+func (h *Health) ready(ctx context.Context) (replicasDown []int, err error) {
+	if h.draining.Load() {
+		return nil, errDraining
+	}
+
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+
+	err = h.DB.PingContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: ping database: %w", errDatabase, err)
+	}
+
+	err = bcc.CheckSchema(ctx, h.DB)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errSchema, err)
+	}
+
+	replicasDown = h.pingReplicas(ctx)
+	if (len(h.Replicas) != 0) && (len(replicasDown) == len(h.Replicas)) {
+		return replicasDown, errReplicas
+	}
+	return replicasDown, nil
+}
+
+// pingReplicas pings the replicas concurrently, so that one that hangs
+// doesn't use up the others' time, and returns the indices of the ones
+// that couldn't be reached.
+func (h *Health) pingReplicas(ctx context.Context) []int {
+	errs := make([]error, len(h.Replicas))
+	var wg sync.WaitGroup
+	for i, r := range h.Replicas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = r.PingContext(ctx)
+		}()
+	}
+	wg.Wait()
+
+	var down []int
+	for i, err := range errs {
+		if err != nil {
+			slog.WarnContext(ctx, "Replica unavailable", "replica", i, "error", err)
+			down = append(down, i)
+		}
+	}
+	return down
+}
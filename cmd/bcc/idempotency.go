@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DeedleFake/backend-code-challenge/bcc"
+)
+
+// maxIdempotencyKeyLen is the longest Idempotency-Key that is accepted.
+const maxIdempotencyKeyLen = 255
+
+// maxIdempotentBodyLen is the largest body that is accepted in a
+// request with an Idempotency-Key, as the body is read into memory to
+// be hashed.
+const maxIdempotentBodyLen = 1 << 20
+
+// Idempotency lets clients safely retry POST requests by sending an
+// Idempotency-Key header. The first request with a given key from a
+// client to an endpoint is served normally and its response is stored
+// for Window. Repeats of it with the same key and body within that
+// time are sent the stored response, marked with an
+// Idempotent-Replayed header, without being served again. Requests
+// that reuse a key with a different body fail with 422, and those made
+// while the first is still being served fail with 409.
+//
+// Responses that say that the request is worth retrying, such as
+// those with a 5xx status or from rate limiting, aren't stored, so the
+// request can be retried with the same key. See retryable. Requests
+// without the header are passed through as is.
+//
+// APIMux applies it to requests to its POST endpoints.
+type Idempotency struct {
+	// DB stores the keys and responses.
+	DB bcc.Beginner
+
+	// Window is how long responses are stored for.
+	Window time.Duration
+
+	// Lease is how long a key stays claimed by a request that hasn't
+	// been completed, such as because the server serving it stopped.
+	// It should be longer than the longest that a request can take to
+	// be served.
+	Lease time.Duration
+
+	// Clients identifies the clients that keys belong to.
+	Clients Clients
+}
+
+// wrap returns a handler that serves requests with next, applying idem
+// to them. A nil Idempotency returns next as is.
+func (idem *Idempotency) wrap(next http.Handler) http.Handler {
+	if idem == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		key := req.Header.Get("Idempotency-Key")
+		if key == "" {
+			next.ServeHTTP(rw, req)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			fail(rw, req, newProblem(http.StatusBadRequest, "bad_request", "Idempotency-Key must be at most "+strconv.FormatInt(maxIdempotencyKeyLen, 10)+" bytes long"), nil)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(rw, req.Body, maxIdempotentBodyLen))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				fail(rw, req, newProblem(http.StatusRequestEntityTooLarge, "request_too_large", "requests with an Idempotency-Key must have a body of at most "+strconv.FormatInt(maxIdempotentBodyLen, 10)+" bytes"), nil)
+				return
+			}
+			fail(rw, req, newProblem(http.StatusBadRequest, "bad_request", "failed to read body"), nil)
+			return
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		hash := sha256.Sum256(body)
+
+		ctx := bcc.WithPrimary(req.Context())
+		k := bcc.IdempotencyKey{
+			Client:   idem.Clients.clientKey(req),
+			Key:      key,
+			Endpoint: req.Method + " " + req.URL.Path,
+		}
+		addLogAttrs(ctx, slog.String("idempotency_key", key))
+
+		prev, err := bcc.ClaimIdempotencyKey(ctx, idem.DB, k, hash[:], idem.Window, idem.Lease)
+		if err != nil {
+			fail(rw, req, problemFor(ctx, err), err)
+			return
+		}
+		if prev != nil {
+			replayIdempotent(rw, req, prev, hash[:])
+			return
+		}
+
+		irw := &idempotencyWriter{ResponseWriter: rw}
+		defer func() {
+			// The response has been sent, so there's nobody left to
+			// tell about errors other than the log.
+			ctx := context.WithoutCancel(ctx)
+			status := irw.status()
+			if retryable(status) {
+				err := bcc.ReleaseIdempotencyKey(ctx, idem.DB, k)
+				if err != nil {
+					slog.ErrorContext(ctx, "Failed to release idempotency key", "error", err)
+				}
+				return
+			}
+
+			err := bcc.CompleteIdempotentRequest(ctx, idem.DB, k, status, irw.Header().Get("Content-Type"), irw.body.Bytes())
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed to store idempotent response", "error", err)
+			}
+		}()
+		next.ServeHTTP(irw, req)
+	})
+}
+
+// retryable returns true if a response with the given status means
+// that the request may succeed if it is made again. Those include
+// 409, as a conflicting request might have finished in the meantime,
+// and 429, as the client may have been given more tokens.
+func retryable(status int) bool {
+	return (status >= 500) || (status == http.StatusConflict) || (status == http.StatusTooManyRequests)
+}
+
+// replayIdempotent responds to a request whose idempotency key has
+// already been claimed by prev.
+func replayIdempotent(rw http.ResponseWriter, req *http.Request, prev *bcc.IdempotentRequest, hash []byte) {
+	if !bytes.Equal(prev.RequestHash, hash) {
+		fail(rw, req, newProblem(http.StatusUnprocessableEntity, "idempotency_key_reused", "Idempotency-Key was already used for a different request"), nil)
+		return
+	}
+	if prev.Status == nil {
+		fail(rw, req, newProblem(http.StatusConflict, "idempotency_key_in_use", "a request with the same Idempotency-Key is still being served"), nil)
+		return
+	}
+
+	addLogAttrs(req.Context(), slog.Bool("idempotent_replay", true))
+
+	h := rw.Header()
+	if prev.ContentType != nil {
+		h.Set("Content-Type", *prev.ContentType)
+	}
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Idempotent-Replayed", "true")
+	rw.WriteHeader(*prev.Status)
+	_, err := rw.Write(prev.Body)
+	if err != nil {
+		slog.WarnContext(req.Context(), "Failed to send response", "error", err)
+	}
+}
+
+// idempotencyWriter keeps a copy of the response that it writes so
+// that it can be stored.
+type idempotencyWriter struct {
+	http.ResponseWriter
+	code int
+	body bytes.Buffer
+}
+
+func (rw *idempotencyWriter) WriteHeader(status int) {
+	if rw.code == 0 {
+		rw.code = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *idempotencyWriter) Write(data []byte) (int, error) {
+	if rw.code == 0 {
+		rw.code = http.StatusOK
+	}
+	rw.body.Write(data)
+	return rw.ResponseWriter.Write(data)
+}
+
+func (rw *idempotencyWriter) status() int {
+	if rw.code == 0 {
+		return http.StatusOK
+	}
+	return rw.code
+}
+
+// expireIdempotencyKeys deletes stored idempotent responses once they
+// are older than window, checking periodically until ctx is canceled.
+func expireIdempotencyKeys(ctx context.Context, db bcc.DB, window time.Duration) {
+	interval := min(window, time.Hour)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		n, err := bcc.ExpireIdempotencyKeys(bcc.WithPrimary(ctx), db, time.Now().Add(-window))
+		if err != nil {
+			slog.Error("Failed to expire idempotency keys", "error", err)
+			continue
+		}
+		if n > 0 {
+			slog.Debug("Expired idempotency keys", "count", n)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/DeedleFake/backend-code-challenge/bcc"
+)
+
+type GetLeaderboardParams struct {
+	Days      int `query:"days" validate:"min=0" desc:"only count ratings given in this many past days, 0 for all time"`
+	MinRaters int `query:"min_raters" validate:"min=0" desc:"minimum number of distinct raters a user needs to be included"`
+	Start     int `query:"start" validate:"min=0" desc:"number of users to skip before returning results"`
+	Limit     int `query:"limit" validate:"min=0,max=100" desc:"maximum number of results to return"`
+}
+
+type GetLeaderboardHandler struct{}
+
+func (h GetLeaderboardHandler) Desc() string {
+	return "get the highest rated users"
+}
+
+func (h GetLeaderboardHandler) Params() interface{} {
+	return &GetLeaderboardParams{
+		MinRaters: 1,
+		Limit:     10,
+	}
+}
+
+func (h GetLeaderboardHandler) Response() interface{} {
+	return []bcc.LeaderboardEntry{}
+}
+
+func (h GetLeaderboardHandler) Serve(req *http.Request, db bcc.DB, params interface{}) (interface{}, error) {
+	q := params.(*GetLeaderboardParams)
+
+	var since time.Time
+	if q.Days > 0 {
+		since = time.Now().AddDate(0, 0, -q.Days)
+	}
+
+	entries, err := bcc.Leaderboard(req.Context(), db, since, q.MinRaters, q.Start, q.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("get leaderboard: %w", err)
+	}
+
+	results, err := bcc.Collect(entries, q.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("iteration: %w", err)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// loaderWait is how long a loader waits for more keys after the
+	// first one of a batch is requested.
+	loaderWait = 2 * time.Millisecond
+
+	// loaderMaxBatch is the most keys that a loader fetches at once.
+	loaderMaxBatch = 100
+)
+
+// loader batches loads of values by key so that resolvers running
+// concurrently can share a single query instead of each making their
+// own. Results, including errors, are cached for the life of the
+// loader, which is meant to be a single request.
+type loader[K comparable, V any] struct {
+	ctx   context.Context
+	fetch func(ctx context.Context, keys []K) (map[K]V, error)
+
+	m       sync.Mutex
+	results map[K]*loadResult[V]
+	pending []K
+}
+
+type loadResult[V any] struct {
+	done chan struct{}
+	val  V
+	ok   bool
+	err  error
+}
+
+// newLoader returns a loader that calls fetch with ctx to load
+// batches of values. fetch should leave keys that have no value out
+// of the map that it returns.
+func newLoader[K comparable, V any](ctx context.Context, fetch func(ctx context.Context, keys []K) (map[K]V, error)) *loader[K, V] {
+	return &loader[K, V]{
+		ctx:     ctx,
+		fetch:   fetch,
+		results: make(map[K]*loadResult[V]),
+	}
+}
+
+// Load returns the value for key, waiting for it to be fetched along
+// with any other keys requested around the same time. ok is false if
+// there is no value for key.
+func (l *loader[K, V]) Load(ctx context.Context, key K) (v V, ok bool, err error) {
+	l.m.Lock()
+	r, cached := l.results[key]
+	if !cached {
+		r = &loadResult[V]{done: make(chan struct{})}
+		l.results[key] = r
+		l.pending = append(l.pending, key)
+		switch len(l.pending) {
+		case 1:
+			time.AfterFunc(loaderWait, l.dispatch)
+		case loaderMaxBatch:
+			go l.dispatch()
+		}
+	}
+	l.m.Unlock()
+
+	select {
+	case <-r.done:
+		return r.val, r.ok, r.err
+	case <-ctx.Done():
+		return v, false, ctx.Err()
+	}
+}
+
+// dispatch fetches the pending keys.
+func (l *loader[K, V]) dispatch() {
+	l.m.Lock()
+	keys := l.pending
+	l.pending = nil
+	l.m.Unlock()
+
+	if len(keys) == 0 {
+		return
+	}
+
+	vals, err := l.safeFetch(keys)
+
+	l.m.Lock()
+	defer l.m.Unlock()
+	for _, key := range keys {
+		r := l.results[key]
+		r.val, r.ok = vals[key]
+		r.err = err
+		close(r.done)
+	}
+}
+
+// safeFetch calls fetch, converting panics into errors, as nothing
+// would recover them otherwise.
+func (l *loader[K, V]) safeFetch(keys []K) (vals map[K]V, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return l.fetch(l.ctx, keys)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// requestLog collects information about a request over the course of
+// serving it so that it can be included in the request's access log
+// entry.
+type requestLog struct {
+	m         sync.Mutex
+	attrs     []slog.Attr
+	queries   int
+	queryTime time.Duration
+}
+
+type requestLogKey struct{}
+
+// withRequestLog returns a copy of ctx that carries a new requestLog.
+func withRequestLog(ctx context.Context) (context.Context, *requestLog) {
+	rl := new(requestLog)
+	return context.WithValue(ctx, requestLogKey{}, rl), rl
+}
+
+// addLogAttrs adds attributes to the access log entry of the request
+// that ctx belongs to. It does nothing if access logging is disabled.
+func addLogAttrs(ctx context.Context, attrs ...slog.Attr) {
+	rl, ok := ctx.Value(requestLogKey{}).(*requestLog)
+	if !ok {
+		return
+	}
+
+	rl.m.Lock()
+	defer rl.m.Unlock()
+	rl.attrs = append(rl.attrs, attrs...)
+}
+
+// logQuery records that a query took dur for the access log entry of
+// the request that ctx belongs to, and logs the query at the debug
+// level. Its signature matches bcc.QueryLogFunc.
+func logQuery(ctx context.Context, query string, dur time.Duration, err error) {
+	if rl, ok := ctx.Value(requestLogKey{}).(*requestLog); ok {
+		rl.m.Lock()
+		rl.queries++
+		rl.queryTime += dur
+		rl.m.Unlock()
+	}
+
+	if !slog.Default().Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("query", strings.Join(strings.Fields(query), " ")),
+		slog.Duration("duration", dur),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	slog.LogAttrs(ctx, slog.LevelDebug, "Query", attrs...)
+}
+
+// paramLogAttrs returns attributes for the fields of the struct
+// pointed to by params that have a log tag. The tag gives the name of
+// the attribute. Zero values are skipped.
+func paramLogAttrs(params interface{}) (attrs []slog.Attr) {
+	v := reflect.Indirect(reflect.ValueOf(params))
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Tag.Get("log")
+		if (name == "") || v.Field(i).IsZero() {
+			continue
+		}
+		attrs = append(attrs, slog.Any(name, v.Field(i).Interface()))
+	}
+	return attrs
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "bcc",
+		Name:      "http_requests_total",
+		Help:      "Number of requests served, by endpoint and status.",
+	}, []string{"method", "path", "status"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "bcc",
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to serve requests, by endpoint.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "path"})
+
+	errorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "bcc",
+		Name:      "http_errors_total",
+		Help:      "Number of problem responses sent, by status and code.",
+	}, []string{"status", "code"})
+
+	grpcRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "bcc",
+		Name:      "grpc_requests_total",
+		Help:      "Number of gRPC calls served, by method and status code.",
+	}, []string{"method", "code"})
+
+	grpcRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "bcc",
+		Name:      "grpc_request_duration_seconds",
+		Help:      "Time taken to serve gRPC calls, by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	timelineDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "bcc",
+		Name:      "timeline_query_duration_seconds",
+		Help:      "Time taken to query and collect a timeline.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	ratingsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "bcc",
+		Name:      "ratings_total",
+		Help:      "Number of rating attempts, by result. Rejected ratings are labeled with the reason.",
+	}, []string{"result"})
+
+	ratingEventsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "bcc",
+		Name:      "rating_events_total",
+		Help:      "Number of rating events emitted because a user's rating passed a whole star.",
+	})
+)
+
+// instrument records the status and duration of every request served
+// by next. Requests that don't match an endpoint are grouped together
+// so that arbitrary paths can't create new series.
+func (mux APIMux) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		method, path := req.Method, req.URL.Path
+		if _, ok := mux.Endpoints[APIMapping{Method: method, Path: path}]; !ok {
+			method, path = "unmatched", "unmatched"
+		}
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: rw}
+		defer func() {
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			requestsTotal.WithLabelValues(method, path, strconv.FormatInt(int64(status), 10)).Inc()
+			requestDuration.WithLabelValues(method, path).Observe(time.Since(start).Seconds())
+		}()
+
+		next.ServeHTTP(rec, req)
+	})
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/DeedleFake/backend-code-challenge/bcc"
+)
+
+// Middleware wraps an http.Handler to add behavior to it, such as
+// logging or setting headers.
+type Middleware func(http.Handler) http.Handler
+
+// chain wraps h in middleware. The first middleware is the outermost,
+// so it sees the request first and the response last.
+func chain(h http.Handler, middleware ...Middleware) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return h
+}
+
+// RequestID is a Middleware that identifies each request. If the
+// request has a valid X-Request-ID header, its value is used.
+// Otherwise, a new ID is generated. The ID is attached to the request's
+// context using bcc.WithRequestID and echoed back to the client in the
+// X-Request-ID header of the response.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		id := req.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		rw.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(rw, req.WithContext(bcc.WithRequestID(req.Context(), id)))
+	})
+}
+
+// Consistency is a Middleware that lets clients choose whether their
+// requests may read from database replicas, which can lag behind the
+// primary. Requests with an X-Consistency header of "strong" only use
+// the primary, so they are guaranteed to see the results of earlier
+// writes. So do requests that aren't GETs, as they may read what they
+// are about to change, unless their endpoint uses readContext because
+// it only reads. Anything else may be served from a replica.
+func Consistency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		strong := strings.EqualFold(req.Header.Get("X-Consistency"), "strong")
+		ctx := context.WithValue(req.Context(), strongKey{}, strong)
+		if (req.Method != "GET") || strong {
+			ctx = bcc.WithPrimary(ctx)
+		}
+		next.ServeHTTP(rw, req.WithContext(ctx))
+	})
+}
+
+type strongKey struct{}
+
+// readContext returns a copy of ctx for a request that only reads,
+// even though it might not be a GET, such as a POST /graphql request.
+// Its reads may go to replicas unless the client asked for strong
+// consistency.
+func readContext(ctx context.Context) context.Context {
+	if strong, _ := ctx.Value(strongKey{}).(bool); strong {
+		return ctx
+	}
+	return bcc.WithReplicas(ctx)
+}
+
+// validRequestID returns true if id is suitable for use as a request
+// ID. IDs end up in logs and headers, so only a limited set of
+// characters are allowed.
+func validRequestID(id string) bool {
+	if (id == "") || (len(id) > 128) {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case (c >= 'a') && (c <= 'z'), (c >= 'A') && (c <= 'Z'), (c >= '0') && (c <= '9'):
+		case strings.ContainsRune("-_.:", c):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Recover is a Middleware that recovers from panics in the handler
+// that it wraps. The stack is logged and, if nothing has been sent to
+// the client yet, a 500 Problem is sent back.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rec := &statusRecorder{ResponseWriter: rw}
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			if r == http.ErrAbortHandler {
+				panic(r)
+			}
+
+			slog.ErrorContext(req.Context(), "Panic", "panic", r, "stack", string(debug.Stack()))
+			if rec.status == 0 {
+				fail(rec, req, newProblem(http.StatusInternalServerError, "internal", "internal server error"), nil)
+			}
+		}()
+
+		next.ServeHTTP(rec, req)
+	})
+}
+
+// statusRecorder is an http.ResponseWriter that remembers the status
+// that was sent.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rw *statusRecorder) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *statusRecorder) Write(data []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	return rw.ResponseWriter.Write(data)
+}
+
+func (rw *statusRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// AccessLog is a Middleware that logs every request along with the
+// status of its response, how long it took, and how much of that time
+// was spent waiting on the database. Endpoints and other Middleware
+// can add to the entry using addLogAttrs.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		ctx, rl := withRequestLog(req.Context())
+		rec := &statusRecorder{ResponseWriter: rw}
+		next.ServeHTTP(rec, req.WithContext(ctx))
+
+		rl.m.Lock()
+		defer rl.m.Unlock()
+
+		attrs := append([]slog.Attr{
+			slog.String("method", req.Method),
+			slog.String("path", req.URL.Path),
+			slog.String("query", req.URL.RawQuery),
+			slog.Int("status", rec.status),
+			slog.Duration("latency", time.Since(start)),
+			slog.Int("sql_queries", rl.queries),
+			slog.Duration("sql_time", rl.queryTime),
+		}, rl.attrs...)
+		slog.LogAttrs(ctx, slog.LevelInfo, "Request", attrs...)
+	})
+}
+
+// timingWriter adds a Server-Timing header right before the headers
+// are sent.
+type timingWriter struct {
+	http.ResponseWriter
+	start time.Time
+	sent  bool
+}
+
+func (rw *timingWriter) WriteHeader(status int) {
+	if !rw.sent {
+		rw.sent = true
+		ms := float64(time.Since(rw.start)) / float64(time.Millisecond)
+		rw.Header().Add("Server-Timing", fmt.Sprintf("app;dur=%.3f", ms))
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *timingWriter) Write(data []byte) (int, error) {
+	if !rw.sent {
+		rw.WriteHeader(http.StatusOK)
+	}
+	return rw.ResponseWriter.Write(data)
+}
+
+func (rw *timingWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Timing is a Middleware that reports how long the server took to
+// produce a response in a Server-Timing header.
+func Timing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(&timingWriter{ResponseWriter: rw, start: time.Now()}, req)
+	})
+}
+
+// CORS returns a Middleware that allows cross-origin requests from the
+// given origins. An origin of "*" allows requests from anywhere.
+// Preflight requests are answered directly without calling the
+// wrapped handler.
+func CORS(origins ...string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			origin := req.Header.Get("Origin")
+			if (origin == "") || !(slices.Contains(origins, "*") || slices.Contains(origins, origin)) {
+				next.ServeHTTP(rw, req)
+				return
+			}
+
+			h := rw.Header()
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Set("Access-Control-Expose-Headers", "API-Version, Deprecation, Sunset, Link, Idempotent-Replayed, RateLimit-Policy, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, Retry-After")
+			h.Add("Vary", "Origin")
+
+			if (req.Method == "OPTIONS") && (req.Header.Get("Access-Control-Request-Method") != "") {
+				h.Set("Access-Control-Allow-Methods", strings.Join([]string{"GET", "POST", "DELETE"}, ", "))
+				h.Set("Access-Control-Allow-Headers", "Content-Type, X-Consistency, API-Version, Idempotency-Key")
+				h.Set("Access-Control-Max-Age", "600")
+				rw.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
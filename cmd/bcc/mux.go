@@ -1,13 +1,15 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"time"
 
-	"github.com/jmoiron/sqlx"
+	"github.com/DeedleFake/backend-code-challenge/bcc"
 )
 
 // APIMapping combines an HTTP method and a URL path.
@@ -24,70 +26,257 @@ type APIEndpoint interface {
 	// Params returns an instance of a type for holding the parameters
 	// of this endpoint. For GET and DELETE requests, this will be
 	// parsed into using parseQuery. For other request types, the body
-	// of the request will be decoded into this object as JSON.
+	// of the request will be decoded into this object as JSON. Fields
+	// are then checked against the rules in their validate tags, if
+	// any, before Serve is called. See fieldRules for details.
 	Params() interface{}
 
 	// Serve serves the endpoint to the client. The params are the value
 	// returned by Params after having been filled. If err is nil then
 	// rsp is encoded to JSON and returned to the client. If rsp and err
-	// are nil, an empty object will be sent back.
-	Serve(req *http.Request, db *sqlx.DB, params interface{}) (rsp interface{}, err error)
+	// are nil, an empty object will be sent back. The request's
+	// context is canceled if the client goes away or the endpoint's
+	// timeout expires, so it should be passed to any database calls.
+	//
+	// If the endpoint is an APITransactor, db is a transaction.
+	Serve(req *http.Request, db bcc.DB, params interface{}) (rsp interface{}, err error)
+}
+
+// APIMiddlewarer is implemented by APIEndpoints that need Middleware
+// applied to them. The Middleware runs inside of the APIMux's own
+// Middleware, right before the request's parameters are parsed.
+type APIMiddlewarer interface {
+	Middleware() []Middleware
+}
+
+// APIResponder is implemented by APIEndpoints that document what they
+// respond with.
+type APIResponder interface {
+	// Response returns a value of the same type as the rsp returned by
+	// Serve. It is purely for documentation purposes.
+	Response() interface{}
+}
+
+// TxMode is the kind of transaction that APIMux runs an endpoint in.
+type TxMode int
+
+const (
+	// TxNone runs the endpoint directly against the database.
+	TxNone TxMode = iota
+
+	// TxReadOnly runs the endpoint in a read-only transaction, so
+	// every query it makes sees the same snapshot of the database.
+	TxReadOnly
+
+	// TxReadWrite runs the endpoint in a transaction that is committed
+	// if Serve succeeds and rolled back if it doesn't.
+	TxReadWrite
+)
+
+// APIValidator is implemented by APIEndpoints that can cheaply tell
+// which version of a response a GET request would get. It is used as
+// the response's ETag, so conditional requests that match it are
+// answered with 304 Not Modified without calling Serve.
+type APIValidator interface {
+	// Validator returns a string that changes whenever the response
+	// to the request would. ok is false if that can't be determined
+	// without serving the request.
+	Validator(req *http.Request, db bcc.DB, params interface{}) (validator string, ok bool)
+}
+
+// APITransactor is implemented by APIEndpoints that need to be run
+// inside of a transaction.
+type APITransactor interface {
+	TxMode() TxMode
+}
+
+// txOptions returns the options to begin h's transaction with, or nil
+// if h shouldn't be run in one.
+func txOptions(h APIEndpoint) *sql.TxOptions {
+	t, ok := h.(APITransactor)
+	if !ok {
+		return nil
+	}
+
+	switch t.TxMode() {
+	case TxReadOnly:
+		return &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	case TxReadWrite:
+		return &sql.TxOptions{}
+	default:
+		return nil
+	}
 }
 
 // APIMux implements a mux for API endpoints as an http.Handler.
 type APIMux struct {
 	// DB is the database connection to hand to the endpoint handlers.
-	DB *sqlx.DB
+	DB bcc.Beginner
 
 	// Endpoints maps methods and paths to handlers.
 	Endpoints map[APIMapping]APIEndpoint
+
+	// Timeout is the maximum amount of time that an endpoint is given
+	// to serve a request before its context is canceled. Zero means no
+	// limit.
+	Timeout time.Duration
+
+	// Timeouts overrides Timeout for specific endpoints.
+	Timeouts map[APIMapping]time.Duration
+
+	// Versions are the versions of the API that are served, oldest
+	// first. Endpoints that implement APIVersioner can be served
+	// differently in each.
+	Versions []APIVersion
+
+	// DefaultVersion is the number of the version that requests are
+	// served in if they don't ask for one.
+	DefaultVersion int
+
+	// RateLimiter, if not nil, limits how often clients can make
+	// requests to each endpoint.
+	RateLimiter *RateLimiter
+
+	// Idempotency, if not nil, lets clients safely retry requests to
+	// POST endpoints.
+	Idempotency *Idempotency
+
+	// Middleware is applied to every request, including those that
+	// don't match any endpoint. Endpoints can add more of their own by
+	// implementing APIMiddlewarer. Every request is given an ID and
+	// recovered from panics regardless of what is in Middleware, and
+	// is counted in the request metrics.
+	Middleware []Middleware
 }
 
 func (mux APIMux) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-	log.Printf("%v %v", req.Method, req.URL.RequestURI())
+	h := Recover(chain(http.HandlerFunc(mux.route), mux.Middleware...))
+	RequestID(mux.version(mux.instrument(h))).ServeHTTP(rw, req)
+}
 
-	rw.Header().Set("Content-Type", "application/json")
+// fail sends p to the client and logs err, if it isn't nil. Errors
+// are only logged as such if they aren't the client's fault.
+func fail(rw http.ResponseWriter, req *http.Request, p Problem, err error) {
+	p.RequestID = bcc.RequestID(req.Context())
+	writeProblem(req.Context(), rw, p)
+	addLogAttrs(req.Context(), slog.String("code", p.Code))
+	if err != nil {
+		level := slog.LevelError
+		if p.Status < http.StatusInternalServerError {
+			level = slog.LevelInfo
+		}
+		slog.Log(req.Context(), level, "Request failed", "status", p.Status, "error", err)
+	}
+}
 
-	h := mux.Endpoints[APIMapping{
+// route finds the endpoint for req and serves it, applying the
+// endpoint's Middleware first.
+func (mux APIMux) route(rw http.ResponseWriter, req *http.Request) {
+	mapping := APIMapping{
 		Method: req.Method,
 		Path:   req.URL.Path,
-	}]
+	}
+	version, err := mux.findVersion(req.Context())
+	if err != nil {
+		fail(rw, req, newProblem(http.StatusNotFound, "invalid_version", err.Error()), nil)
+		return
+	}
+	version.setHeaders(rw.Header())
+	addLogAttrs(req.Context(), slog.String("api_version", version.String()))
+	req = req.WithContext(context.WithValue(req.Context(), apiVersionKey{}, version))
+
+	h := mux.Endpoints[mapping]
+	if v, ok := h.(APIVersioner); ok {
+		h = v.ForVersion(version.Number)
+	}
 	if h == nil {
-		http.Error(rw, `{"error": "invalid endpoint"}`, http.StatusNotFound)
+		fail(rw, req, newProblem(http.StatusNotFound, "invalid_endpoint", "invalid endpoint"), nil)
+		return
+	}
+	if !mux.RateLimiter.allow(rw, req, mapping) {
+		return
+	}
+
+	var handler http.Handler = endpointHandler{mux: mux, mapping: mapping, h: h}
+	if m, ok := h.(APIMiddlewarer); ok {
+		handler = chain(handler, m.Middleware()...)
+	}
+	if mapping.Method == "POST" {
+		handler = mux.Idempotency.wrap(handler)
+	}
+	handler.ServeHTTP(rw, req)
+}
+
+// endpointHandler adapts an APIEndpoint to an http.Handler.
+type endpointHandler struct {
+	mux     APIMux
+	mapping APIMapping
+	h       APIEndpoint
+}
+
+func (eh endpointHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	timeout := eh.mux.Timeout
+	if t, ok := eh.mux.Timeouts[eh.mapping]; ok {
+		timeout = t
+	}
+	if timeout > 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), timeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+
+	addLogAttrs(req.Context(), slog.String("endpoint", eh.mapping.Method+" "+eh.mapping.Path))
+
+	rw.Header().Add("Vary", "Accept")
+	c, ok := codecFor(req.Header.Get("Accept"))
+	if !ok {
+		fail(rw, req, notAcceptable(), nil)
 		return
 	}
 
-	params := h.Params()
+	params := eh.h.Params()
+	tag := "json"
 	switch req.Method {
 	case "GET", "DELETE":
+		tag = "query"
 		err := parseQuery(req.URL.Query(), params)
 		if err != nil {
-			http.Error(rw, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusBadRequest)
+			fail(rw, req, newProblem(http.StatusBadRequest, "bad_request", err.Error()), nil)
 			return
 		}
 
 	default:
 		err := json.NewDecoder(req.Body).Decode(params)
 		if err != nil {
-			http.Error(rw, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusBadRequest)
+			fail(rw, req, newProblem(http.StatusBadRequest, "bad_request", err.Error()), nil)
+			return
 		}
 	}
 
-	rsp, err := h.Serve(req, mux.DB, params)
+	addLogAttrs(req.Context(), paramLogAttrs(params)...)
+
+	fieldErrs, err := validate(params, tag)
 	if err != nil {
-		errJSON := `{"error":"internal server error"}`
-		status := http.StatusInternalServerError
-
-		var userErr APIUserError
-		if errors.As(err, &userErr) {
-			errJSON = fmt.Sprintf(`{"error":%q}`, userErr.Error())
-			if userErr.Status != 0 {
-				status = userErr.Status
-			}
-		}
+		fail(rw, req, newProblem(http.StatusInternalServerError, "internal", "internal server error"), fmt.Errorf("validate: %w", err))
+		return
+	}
+	if len(fieldErrs) != 0 {
+		p := newProblem(http.StatusUnprocessableEntity, "validation_failed", "invalid parameters")
+		p.Errors = fieldErrs
+		fail(rw, req, p, nil)
+		return
+	}
+
+	if etag, ok := eh.etag(req, c, params); ok && notModified(req, etag, time.Time{}) {
+		rw.Header().Set("ETag", etag)
+		rw.Header().Set("Cache-Control", "no-cache")
+		rw.WriteHeader(http.StatusNotModified)
+		return
+	}
 
-		http.Error(rw, errJSON, status)
-		log.Printf("Error: %v", err)
+	rsp, err := eh.mux.serve(req, eh.h, params)
+	if err != nil {
+		fail(rw, req, problemFor(req.Context(), err), err)
 		return
 	}
 
@@ -95,17 +284,67 @@ func (mux APIMux) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		rsp = struct{}{}
 	}
 
-	err = json.NewEncoder(rw).Encode(rsp)
+	// Serving the request may have filled the cache that the
+	// validator depends on, so it's checked again.
+	etag, _ := eh.etag(req, c, params)
+	writeResponse(rw, req, c, rsp, etag)
+}
+
+// etag returns the ETag of the response to a GET request, encoded
+// with c, if the endpoint is an APIValidator that can determine it.
+func (eh endpointHandler) etag(req *http.Request, c codec, params interface{}) (string, bool) {
+	v, ok := eh.h.(APIValidator)
+	if !ok || (req.Method != "GET") {
+		return "", false
+	}
+	validator, ok := v.Validator(req, eh.mux.DB, params)
+	if !ok {
+		return "", false
+	}
+	return validatorETag(validator, c, apiVersion(req.Context()).Number), true
+}
+
+// serve calls h.Serve, wrapping it in a transaction if h asks for
+// one.
+func (mux APIMux) serve(req *http.Request, h APIEndpoint, params interface{}) (rsp interface{}, err error) {
+	opts := txOptions(h)
+	if opts == nil {
+		return h.Serve(req, mux.DB, params)
+	}
+
+	tx, err := mux.DB.Begin(req.Context(), opts)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	rsp, err = h.Serve(req, tx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
 	if err != nil {
-		log.Printf("Error sending response: %v", err)
+		return nil, fmt.Errorf("commit: %w", err)
 	}
+
+	return rsp, nil
 }
 
 // APIUserError is returned by APIEndpoints that want to send error
 // data back to the user. If Status is zero, it is presumed to be
-// StatusInternalServerError.
+// StatusInternalServerError. If Code is empty, one is picked based on
+// Status.
+//
+// Errors from package bcc, such as bcc.ErrNotFound, don't need to be
+// wrapped in an APIUserError, as APIMux recognizes them on its own.
 type APIUserError struct {
 	Status int
+	Code   string
 	Err    error
 }
 
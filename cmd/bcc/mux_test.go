@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMuxRecoversMiddleware(t *testing.T) {
+	panicky := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			panic("middleware")
+		})
+	}
+
+	mux := APIMux{
+		Endpoints: map[APIMapping]APIEndpoint{
+			{"GET", "/test"}: testEndpoint{},
+		},
+		Versions:       []APIVersion{{Number: 1}},
+		DefaultVersion: 1,
+		Middleware:     []Middleware{Timing, Compress, panicky},
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/test", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status is %v, want %v", rec.Code, http.StatusInternalServerError)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Content-Type is %q, want %q", ct, "application/problem+json")
+	}
+}
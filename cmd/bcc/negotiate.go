@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// acceptValue is an entry in an Accept or Accept-Encoding header.
+type acceptValue struct {
+	value string
+	q     float64
+}
+
+// parseAccept parses the value of an Accept-style header, dropping any
+// parameters other than q. Values are returned lowercased.
+func parseAccept(header string) []acceptValue {
+	var values []acceptValue
+	for _, part := range strings.Split(header, ",") {
+		value, params, _ := strings.Cut(part, ";")
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(k, "q") {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+
+		values = append(values, acceptValue{value: value, q: q})
+	}
+	return values
+}
+
+// negotiate returns the element of offers that is most preferred by
+// the given Accept-style header. Ties are broken by the order of
+// offers. match reports whether an offer satisfies a value from the
+// header. If nothing is acceptable, negotiate returns "".
+func negotiate(header string, offers []string, match func(value, offer string) bool) string {
+	accepted := parseAccept(header)
+
+	var best string
+	var bestQ float64
+	for _, offer := range offers {
+		// The most specific value that matches an offer determines its
+		// q, but the header is rarely long or specific enough for it to
+		// matter, so the highest q among matches is used instead.
+		q := -1.0
+		for _, a := range accepted {
+			if match(a.value, offer) && (a.q > q) {
+				q = a.q
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// codec encodes responses in a particular format.
+type codec struct {
+	contentType string
+	aliases     []string
+	encode      func(w io.Writer, v interface{}) error
+}
+
+// codecs are the formats that responses can be sent in, in order of
+// preference. Each encodes the same values in the same way, using
+// their json tags.
+var codecs = []codec{
+	{
+		contentType: "application/json",
+		encode: func(w io.Writer, v interface{}) error {
+			return json.NewEncoder(w).Encode(v)
+		},
+	},
+	{
+		contentType: "application/cbor",
+		encode: func(w io.Writer, v interface{}) error {
+			return cborEncMode.NewEncoder(w).Encode(v)
+		},
+	},
+	{
+		contentType: "application/msgpack",
+		aliases:     []string{"application/x-msgpack", "application/vnd.msgpack"},
+		encode: func(w io.Writer, v interface{}) error {
+			e := msgpack.NewEncoder(w)
+			e.SetCustomStructTag("json")
+			return e.Encode(v)
+		},
+	},
+}
+
+var cborEncMode = func() cbor.EncMode {
+	mode, err := cbor.EncOptions{
+		Time:    cbor.TimeRFC3339Nano,
+		TimeTag: cbor.EncTagRequired,
+	}.EncMode()
+	if err != nil {
+		panic(err)
+	}
+	return mode
+}()
+
+// codecFor returns the codec to respond to a request with the given
+// Accept header with. If the header is empty, JSON is used. If the
+// client doesn't accept any of the codecs, ok is false.
+func codecFor(accept string) (c codec, ok bool) {
+	if accept == "" {
+		return codecs[0], true
+	}
+
+	offers := make([]string, 0, len(codecs))
+	for _, c := range codecs {
+		offers = append(offers, c.contentType)
+	}
+	best := negotiate(accept, offers, func(value, offer string) bool {
+		if (value == "*/*") || (value == "application/*") || (value == offer) {
+			return true
+		}
+		i := slices.IndexFunc(codecs, func(c codec) bool { return c.contentType == offer })
+		return slices.Contains(codecs[i].aliases, value)
+	})
+
+	for _, c := range codecs {
+		if c.contentType == best {
+			return c, true
+		}
+	}
+	return codec{}, false
+}
+
+// notAcceptable returns the Problem to send to clients that don't
+// accept any of the codecs.
+func notAcceptable() Problem {
+	types := make([]string, 0, len(codecs))
+	for _, c := range codecs {
+		types = append(types, c.contentType)
+	}
+	return newProblem(http.StatusNotAcceptable, "not_acceptable", "responses can only be sent as "+strings.Join(types, ", "))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DeedleFake/backend-code-challenge/bcc"
+)
+
+func TestCodecFor(t *testing.T) {
+	tests := []struct {
+		accept      string
+		contentType string
+		ok          bool
+	}{
+		{accept: "", contentType: "application/json", ok: true},
+		{accept: "*/*", contentType: "application/json", ok: true},
+		{accept: "application/*", contentType: "application/json", ok: true},
+		{accept: "application/json", contentType: "application/json", ok: true},
+		{accept: "application/cbor", contentType: "application/cbor", ok: true},
+		{accept: "APPLICATION/CBOR", contentType: "application/cbor", ok: true},
+		{accept: "application/msgpack", contentType: "application/msgpack", ok: true},
+		{accept: "application/x-msgpack", contentType: "application/msgpack", ok: true},
+		{accept: "application/vnd.msgpack", contentType: "application/msgpack", ok: true},
+		{accept: "application/json;q=0.5, application/cbor", contentType: "application/cbor", ok: true},
+		{accept: "application/cbor;q=0.9, */*;q=0.1", contentType: "application/cbor", ok: true},
+		{accept: "text/html, */*;q=0.1", contentType: "application/json", ok: true},
+		{accept: "application/cbor, application/msgpack", contentType: "application/cbor", ok: true},
+		{accept: "text/html", ok: false},
+		{accept: "application/xml, text/*", ok: false},
+		{accept: "application/json;q=0", ok: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.accept, func(t *testing.T) {
+			c, ok := codecFor(test.accept)
+			if ok != test.ok {
+				t.Fatalf("ok is %v, want %v", ok, test.ok)
+			}
+			if c.contentType != test.contentType {
+				t.Errorf("got %q, want %q", c.contentType, test.contentType)
+			}
+		})
+	}
+}
+
+func TestNotAcceptable(t *testing.T) {
+	p := notAcceptable()
+	if (p.Status != http.StatusNotAcceptable) || (p.Code != "not_acceptable") {
+		t.Errorf("got %v %q, want %v %q", p.Status, p.Code, http.StatusNotAcceptable, "not_acceptable")
+	}
+}
+
+type testEndpoint struct{}
+
+func (h testEndpoint) Desc() string        { return "test" }
+func (h testEndpoint) Params() interface{} { return &struct{}{} }
+func (h testEndpoint) Serve(req *http.Request, db bcc.DB, params interface{}) (interface{}, error) {
+	return map[string]string{"ok": "yes"}, nil
+}
+
+func TestMuxNotAcceptable(t *testing.T) {
+	mux := APIMux{
+		Endpoints: map[APIMapping]APIEndpoint{
+			{"GET", "/test"}: testEndpoint{},
+		},
+		Versions:       []APIVersion{{Number: 1}},
+		DefaultVersion: 1,
+	}
+
+	tests := []struct {
+		accept      string
+		status      int
+		contentType string
+	}{
+		{accept: "", status: http.StatusOK, contentType: "application/json"},
+		{accept: "*/*", status: http.StatusOK, contentType: "application/json"},
+		{accept: "text/html", status: http.StatusNotAcceptable, contentType: "application/problem+json"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.accept, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.Header.Set("Accept", test.accept)
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != test.status {
+				t.Errorf("status is %v, want %v", rec.Code, test.status)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != test.contentType {
+				t.Errorf("Content-Type is %q, want %q", ct, test.contentType)
+			}
+		})
+	}
+}
@@ -0,0 +1,452 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DeedleFake/backend-code-challenge/bcc"
+)
+
+// openAPIDoc is an OpenAPI 3 document. Only the parts of the
+// specification that are needed to describe an APIMux are included.
+type openAPIDoc struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas map[string]*openAPISchema `json:"schemas"`
+}
+
+type openAPIOperation struct {
+	Summary     string                     `json:"summary"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+
+	// Security lists the security requirements of the operation. No
+	// endpoint requires authentication yet, so this is always empty,
+	// which explicitly marks the operation as not needing any.
+	Security []map[string][]string `json:"security"`
+}
+
+type openAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Ref         string `json:"$ref,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Format      string `json:"format,omitempty"`
+	Description string `json:"description,omitempty"`
+	Nullable    bool   `json:"nullable,omitempty"`
+
+	Minimum   *float64      `json:"minimum,omitempty"`
+	Maximum   *float64      `json:"maximum,omitempty"`
+	MinLength *int          `json:"minLength,omitempty"`
+	MaxLength *int          `json:"maxLength,omitempty"`
+	MinItems  *int          `json:"minItems,omitempty"`
+	MaxItems  *int          `json:"maxItems,omitempty"`
+	Pattern   string        `json:"pattern,omitempty"`
+	Enum      []interface{} `json:"enum,omitempty"`
+	Default   interface{}   `json:"default,omitempty"`
+
+	Items *openAPISchema `json:"items,omitempty"`
+
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+
+	OneOf         []*openAPISchema      `json:"oneOf,omitempty"`
+	Discriminator *openAPIDiscriminator `json:"discriminator,omitempty"`
+}
+
+type openAPIDiscriminator struct {
+	PropertyName string            `json:"propertyName"`
+	Mapping      map[string]string `json:"mapping,omitempty"`
+}
+
+// openAPIUnion is implemented by struct types that are encoded as
+// tagged unions. The property named by unionTag says which one of the
+// other properties is set, and its value is the name of that property.
+type openAPIUnion interface {
+	unionTag() string
+}
+
+// openAPISchemas converts Go types into OpenAPI schemas, collecting
+// named struct types as reusable components.
+type openAPISchemas map[string]*openAPISchema
+
+func (s openAPISchemas) ref(name string) *openAPISchema {
+	return &openAPISchema{Ref: "#/components/schemas/" + name}
+}
+
+// schemaOf returns the schema of values of type t as they would be
+// encoded by encoding/json.
+func (s openAPISchemas) schemaOf(t reflect.Type) *openAPISchema {
+	switch t {
+	case reflect.TypeOf(time.Time{}):
+		return &openAPISchema{Type: "string", Format: "date-time"}
+	case reflect.TypeOf(json.RawMessage{}):
+		// Any JSON value.
+		return &openAPISchema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := s.schemaOf(t.Elem())
+		if schema.Ref != "" {
+			return schema
+		}
+		schema.Nullable = true
+		return schema
+
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &openAPISchema{Type: "integer", Format: "int32"}
+
+	case reflect.Int, reflect.Int64:
+		return &openAPISchema{Type: "integer", Format: "int64"}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var zero float64
+		return &openAPISchema{Type: "integer", Format: "int64", Minimum: &zero}
+
+	case reflect.Float32:
+		return &openAPISchema{Type: "number", Format: "float"}
+
+	case reflect.Float64:
+		return &openAPISchema{Type: "number", Format: "double"}
+
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+
+	case reflect.Slice, reflect.Array:
+		return &openAPISchema{Type: "array", Items: s.schemaOf(t.Elem())}
+
+	case reflect.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: s.schemaOf(t.Elem())}
+
+	case reflect.Interface:
+		// Any JSON value, as nothing more is known about it.
+		return &openAPISchema{}
+
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structSchema(t, "json")
+		}
+		if _, ok := s[t.Name()]; !ok {
+			// Reserve the name first in case the type refers to itself.
+			s[t.Name()] = nil
+			if u, ok := reflect.Zero(t).Interface().(openAPIUnion); ok {
+				s[t.Name()] = s.unionSchema(t, u.unionTag())
+			} else {
+				s[t.Name()] = s.structSchema(t, "json")
+			}
+		}
+		return s.ref(t.Name())
+
+	default:
+		return &openAPISchema{}
+	}
+}
+
+// unionSchema returns a oneOf schema for the struct type t, which is
+// encoded as a tagged union with the property named by tag as its
+// discriminator. Each of the other properties gets a component of its
+// own that the discriminator maps to.
+func (s openAPISchemas) unionSchema(t reflect.Type, tag string) *openAPISchema {
+	fields := s.structSchema(t, "json")
+
+	names := make([]string, 0, len(fields.Properties))
+	for name := range fields.Properties {
+		if name != tag {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	schema := &openAPISchema{
+		Discriminator: &openAPIDiscriminator{
+			PropertyName: tag,
+			Mapping:      make(map[string]string, len(names)),
+		},
+	}
+	for _, name := range names {
+		variant := t.Name() + "." + name
+		s[variant] = &openAPISchema{
+			Type: "object",
+			Properties: map[string]*openAPISchema{
+				tag:  {Type: "string", Enum: []interface{}{name}},
+				name: fields.Properties[name],
+			},
+			Required: []string{tag, name},
+		}
+
+		ref := s.ref(variant)
+		schema.OneOf = append(schema.OneOf, ref)
+		schema.Discriminator.Mapping[name] = ref.Ref
+	}
+	return schema
+}
+
+// structSchema returns an object schema for the struct type t. Field
+// names are taken from the given tag, falling back on the name of the
+// field, and descriptions are taken from the desc tag. Fields are
+// required if their validate tag says so. As with encoding/json, the
+// fields of embedded structs without a name in the tag are included as
+// if they were fields of t.
+func (s openAPISchemas) structSchema(t reflect.Type, tag string) *openAPISchema {
+	schema := &openAPISchema{
+		Type:       "object",
+		Properties: make(map[string]*openAPISchema, t.NumField()),
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && (f.Type.Kind() == reflect.Struct) && (f.Tag.Get(tag) == "") {
+			embedded := s.structSchema(f.Type, tag)
+			for name, prop := range embedded.Properties {
+				schema.Properties[name] = prop
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := s.schemaOf(f.Type)
+		if desc := f.Tag.Get("desc"); desc != "" {
+			if prop.Ref != "" {
+				prop = &openAPISchema{Ref: prop.Ref}
+			}
+			prop.Description = desc
+		}
+		schema.Properties[name] = prop
+
+		// Malformed tags are reported by paramsSchema and validate.
+		if rules, err := parseRules(f.Tag.Get("validate")); (err == nil) && rules.Required {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
+
+// paramsSchema returns the schema of an endpoint's parameters. Unlike
+// schemaOf, it documents non-zero fields of params as default values
+// and includes the rest of the rules from validate tags.
+func (s openAPISchemas) paramsSchema(params interface{}, tag string) (*openAPISchema, error) {
+	v := reflect.Indirect(reflect.ValueOf(params))
+	schema := s.structSchema(v.Type(), tag)
+
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		name := fieldName(f, tag)
+		prop, ok := schema.Properties[name]
+		if !ok {
+			continue
+		}
+
+		if !v.Field(i).IsZero() {
+			prop.Default = v.Field(i).Interface()
+		}
+
+		rules, err := parseRules(f.Tag.Get("validate"))
+		if err != nil {
+			return nil, fmt.Errorf("field %v: %w", f.Name, err)
+		}
+		if rules.Min != nil {
+			prop.Minimum = rules.Min
+		}
+		prop.Maximum = rules.Max
+		if f.Type.Kind() == reflect.Slice {
+			prop.MinItems = rules.MinLen
+			prop.MaxItems = rules.MaxLen
+		} else {
+			prop.MinLength = rules.MinLen
+			prop.MaxLength = rules.MaxLen
+		}
+		if rules.Pattern != nil {
+			prop.Pattern = rules.Pattern.String()
+		}
+		for _, e := range rules.Enum {
+			prop.Enum = append(prop.Enum, e)
+		}
+	}
+
+	return schema, nil
+}
+
+// openAPI generates an OpenAPI document describing endpoints. It
+// returns an error only if an endpoint's validate tags are malformed.
+func openAPI(endpoints map[APIMapping]APIEndpoint) (*openAPIDoc, error) {
+	schemas := make(openAPISchemas)
+	problem := schemas.schemaOf(reflect.TypeOf(Problem{}))
+
+	doc := &openAPIDoc{
+		OpenAPI: "3.0.3",
+		Info: openAPIInfo{
+			Title:       "bcc",
+			Description: "bcc is a demo social media API backend.",
+			Version:     "1",
+		},
+		Paths: make(map[string]map[string]*openAPIOperation),
+		Components: openAPIComponents{
+			Schemas: schemas,
+		},
+	}
+
+	for m, h := range endpoints {
+		op := &openAPIOperation{
+			Summary:   h.Desc(),
+			Responses: make(map[string]openAPIResponse),
+			Security:  []map[string][]string{},
+		}
+
+		switch m.Method {
+		case "GET", "DELETE":
+			params, err := schemas.paramsSchema(h.Params(), "query")
+			if err != nil {
+				return nil, fmt.Errorf("%v %v: %w", m.Method, m.Path, err)
+			}
+
+			names := make([]string, 0, len(params.Properties))
+			for name := range params.Properties {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			for _, name := range names {
+				prop := params.Properties[name]
+				desc := prop.Description
+				prop.Description = ""
+
+				op.Parameters = append(op.Parameters, openAPIParameter{
+					Name:        name,
+					In:          "query",
+					Description: desc,
+					Required:    slices.Contains(params.Required, name),
+					Schema:      prop,
+				})
+			}
+
+		default:
+			params, err := schemas.paramsSchema(h.Params(), "json")
+			if err != nil {
+				return nil, fmt.Errorf("%v %v: %w", m.Method, m.Path, err)
+			}
+
+			op.RequestBody = &openAPIRequestBody{
+				Required: true,
+				Content: map[string]openAPIMediaType{
+					"application/json": {Schema: params},
+				},
+			}
+		}
+
+		rsp := &openAPISchema{Type: "object"}
+		if r, ok := h.(APIResponder); ok {
+			rsp = schemas.schemaOf(reflect.TypeOf(r.Response()))
+		}
+		content := make(map[string]openAPIMediaType, len(codecs))
+		for _, c := range codecs {
+			content[c.contentType] = openAPIMediaType{Schema: rsp}
+		}
+		op.Responses["200"] = openAPIResponse{
+			Description: "success",
+			Content:     content,
+		}
+		if m.Method == "GET" {
+			op.Responses["304"] = openAPIResponse{
+				Description: "not modified since the version identified by If-None-Match or If-Modified-Since",
+			}
+		}
+		op.Responses["422"] = openAPIResponse{
+			Description: "invalid parameters",
+			Content: map[string]openAPIMediaType{
+				"application/problem+json": {Schema: problem},
+			},
+		}
+		op.Responses["default"] = openAPIResponse{
+			Description: "error",
+			Content: map[string]openAPIMediaType{
+				"application/problem+json": {Schema: problem},
+			},
+		}
+
+		path := doc.Paths[m.Path]
+		if path == nil {
+			path = make(map[string]*openAPIOperation)
+			doc.Paths[m.Path] = path
+		}
+		path[strings.ToLower(m.Method)] = op
+	}
+
+	return doc, nil
+}
+
+// OpenAPIHandler serves an OpenAPI document describing the endpoints
+// of an APIMux.
+type OpenAPIHandler struct {
+	Endpoints map[APIMapping]APIEndpoint
+}
+
+func (h OpenAPIHandler) Desc() string {
+	return "get an OpenAPI 3 description of the API"
+}
+
+func (h OpenAPIHandler) Params() interface{} {
+	return &struct{}{}
+}
+
+func (h OpenAPIHandler) Middleware() []Middleware {
+	// Allow documentation tools running in browsers to fetch the
+	// document no matter where they're hosted.
+	return []Middleware{CORS("*")}
+}
+
+func (h OpenAPIHandler) Serve(req *http.Request, db bcc.DB, params interface{}) (interface{}, error) {
+	return openAPI(h.Endpoints)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestSchemaOf(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{name: "RawMessage", v: json.RawMessage{}, want: `{}`},
+		{name: "Map", v: map[string]json.RawMessage{}, want: `{"type":"object","additionalProperties":{}}`},
+		{name: "MapOfInts", v: map[string]int32{}, want: `{"type":"object","additionalProperties":{"type":"integer","format":"int32"}}`},
+		{name: "Interface", v: struct{ V interface{} }{}, want: `{"type":"object","properties":{"V":{}}}`},
+		{
+			name: "RequiredFromValidate",
+			v: struct {
+				A string `json:"a" validate:"required"`
+				B string `json:"b"`
+				C string `json:"c,omitempty" validate:"required,maxlen=3"`
+			}{},
+			want: `{"type":"object","properties":{"a":{"type":"string"},"b":{"type":"string"},"c":{"type":"string"}},"required":["a","c"]}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schema := make(openAPISchemas).schemaOf(reflect.TypeOf(test.v))
+			got, err := json.Marshal(schema)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestUnionSchema(t *testing.T) {
+	schemas := make(openAPISchemas)
+	ref := schemas.schemaOf(reflect.TypeOf(TimelineEntryV2{}))
+	if ref.Ref != "#/components/schemas/TimelineEntryV2" {
+		t.Fatalf("got %+v, want a reference", ref)
+	}
+
+	schema := schemas["TimelineEntryV2"]
+	if (schema.Discriminator == nil) || (schema.Discriminator.PropertyName != "type") {
+		t.Fatalf("discriminator is %+v", schema.Discriminator)
+	}
+
+	types := []string{"comment", "github_event", "post", "rating_milestone"}
+	if len(schema.OneOf) != len(types) {
+		t.Fatalf("got %v variants, want %v", len(schema.OneOf), len(types))
+	}
+	for i, typ := range types {
+		ref := "#/components/schemas/TimelineEntryV2." + typ
+		if schema.OneOf[i].Ref != ref {
+			t.Errorf("variant %v is %q, want %q", i, schema.OneOf[i].Ref, ref)
+		}
+		if m := schema.Discriminator.Mapping[typ]; m != ref {
+			t.Errorf("%q maps to %q, want %q", typ, m, ref)
+		}
+
+		variant := schemas["TimelineEntryV2."+typ]
+		if !slices.Equal(variant.Required, []string{"type", typ}) {
+			t.Errorf("%q requires %q", typ, variant.Required)
+		}
+		if !slices.Equal(variant.Properties["type"].Enum, []interface{}{typ}) {
+			t.Errorf("%q has type %v", typ, variant.Properties["type"].Enum)
+		}
+	}
+}
@@ -1,17 +1,47 @@
 package main
 
 import (
-	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/DeedleFake/backend-code-challenge/bcc"
-	"github.com/jmoiron/sqlx"
 )
 
 type GetPostParams struct {
-	PostID uint64 `query:"post_id" desc:"ID of the post being fetched"`
+	PostID uint64 `query:"post_id" validate:"required" desc:"ID of the post being fetched"`
+}
+
+// PostResponse is the response to a GET /post request.
+type PostResponse struct {
+	UserID    uint64    `json:"user_id"`
+	PostedAt  time.Time `json:"posted_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Title    string                `json:"title"`
+	Body     string                `json:"body"`
+	Comments []PostResponseComment `json:"comments"`
+}
+
+// LastModified returns the latest time at which the post or any of its
+// comments was updated.
+func (rsp PostResponse) LastModified() time.Time {
+	last := rsp.UpdatedAt
+	for _, c := range rsp.Comments {
+		if c.UpdatedAt.After(last) {
+			last = c.UpdatedAt
+		}
+	}
+	return last
+}
+
+// PostResponseComment is a comment in a PostResponse.
+type PostResponseComment struct {
+	UserID    uint64    `json:"user_id"`
+	PostedAt  time.Time `json:"posted_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	ID        uint64    `json:"id"`
+	Message   string    `json:"message"`
 }
 
 type GetPostHandler struct{}
@@ -24,47 +54,30 @@ func (h GetPostHandler) Params() interface{} {
 	return &GetPostParams{}
 }
 
-func (h GetPostHandler) Serve(req *http.Request, db *sqlx.DB, params interface{}) (interface{}, error) {
+func (h GetPostHandler) Response() interface{} {
+	return PostResponse{}
+}
+
+func (h GetPostHandler) Serve(req *http.Request, db bcc.DB, params interface{}) (interface{}, error) {
 	q := params.(*GetPostParams)
 
-	post, err := bcc.GetPostByID(db, q.PostID)
+	post, comments, err := bcc.PostWithComments(req.Context(), db, q.PostID)
 	if err != nil {
 		return nil, fmt.Errorf("post: %w", err)
 	}
 
-	comments, err := bcc.GetCommentsByPostID(db, q.PostID)
-	if err != nil {
-		return nil, fmt.Errorf("comments: %w", err)
-	}
-	defer comments.Close()
-
-	result := struct {
-		UserID    uint64    `json:"user_id"`
-		PostedAt  time.Time `json:"posted_at"`
-		UpdatedAt time.Time `json:"updated_at"`
-
-		Title    string        `json:"title"`
-		Body     string        `json:"body"`
-		Comments []interface{} `json:"comments"`
-	}{
+	result := PostResponse{
 		UserID:    post.UserID,
 		PostedAt:  post.PostedAt,
 		UpdatedAt: post.UpdatedAt,
 
-		Title: post.Title,
-		Body:  post.Body,
+		Title:    post.Title,
+		Body:     post.Body,
+		Comments: make([]PostResponseComment, 0, len(comments)),
 	}
 
-	for comments.Next() {
-		comment := comments.Current().(bcc.Comment)
-
-		result.Comments = append(result.Comments, struct {
-			UserID    uint64    `json:"user_id"`
-			PostedAt  time.Time `"json:"posted_at"`
-			UpdatedAt time.Time `json:"updated_at"`
-			ID        uint64    `json:"id"`
-			Message   string    `json:"message"`
-		}{
+	for _, comment := range comments {
+		result.Comments = append(result.Comments, PostResponseComment{
 			UserID:    comment.UserID,
 			PostedAt:  comment.CommentedAt,
 			UpdatedAt: comment.UpdatedAt,
@@ -72,16 +85,13 @@ func (h GetPostHandler) Serve(req *http.Request, db *sqlx.DB, params interface{}
 			Message:   comment.Message,
 		})
 	}
-	if err := comments.Err(); err != nil {
-		return nil, fmt.Errorf("comments iteration: %w", err)
-	}
 
 	return result, nil
 }
 
 type PostPostParams struct {
-	UserID uint64 `json:"user_id" desc:"ID of the user making the post"`
-	Title  string `json:"title" desc:"title of the post being made, must not be blank"`
+	UserID uint64 `json:"user_id" validate:"required" desc:"ID of the user making the post" log:"user_id"`
+	Title  string `json:"title" validate:"required" desc:"title of the post being made"`
 	Body   string `json:"body" desc:"contents of the post being made"`
 }
 
@@ -95,13 +105,10 @@ func (h PostPostHandler) Params() interface{} {
 	return &PostPostParams{}
 }
 
-func (h PostPostHandler) Serve(req *http.Request, db *sqlx.DB, params interface{}) (interface{}, error) {
+func (h PostPostHandler) Serve(req *http.Request, db bcc.DB, params interface{}) (interface{}, error) {
 	q := params.(*PostPostParams)
-	if q.Title == "" {
-		return nil, BadRequest(errors.New("title must not be blank"))
-	}
 
-	err := bcc.CreatePost(db, q.UserID, q.Title, q.Body)
+	err := bcc.CreatePost(req.Context(), db, q.UserID, q.Title, q.Body)
 	if err != nil {
 		return nil, fmt.Errorf("create post: %w", err)
 	}
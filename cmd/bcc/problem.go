@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/DeedleFake/backend-code-challenge/bcc"
+)
+
+// Problem is an RFC 7807 problem details object. Every error response
+// sent by APIMux is one of these, encoded as application/problem+json.
+type Problem struct {
+	// Type is always "about:blank", as Code is used to distinguish
+	// between different problems instead.
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+
+	// Code is a stable, machine-readable identifier for the problem,
+	// such as "not_found".
+	Code string `json:"code"`
+
+	// RequestID identifies the request that caused the problem. It is
+	// also included in the server's logs.
+	RequestID string `json:"request_id,omitempty"`
+
+	// Errors lists the individual problems with the request's
+	// parameters when Code is "validation_failed".
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// newProblem returns a Problem with the given status, code, and
+// detail.
+func newProblem(status int, code, detail string) Problem {
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// errorKinds maps the kinds of errors returned by package bcc to
+// statuses and codes.
+var errorKinds = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{bcc.ErrNotFound, http.StatusNotFound, "not_found"},
+	{bcc.ErrConflict, http.StatusConflict, "conflict"},
+	{bcc.ErrForbidden, http.StatusForbidden, "forbidden"},
+	{bcc.ErrInvalid, http.StatusUnprocessableEntity, "invalid"},
+	{bcc.ErrRateLimited, http.StatusTooManyRequests, "rate_limited"},
+}
+
+// codeForStatus returns the code to use for an APIUserError that
+// doesn't specify one.
+func codeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "bad_request"
+	case http.StatusInternalServerError:
+		return "internal"
+	}
+	for _, kind := range errorKinds {
+		if kind.status == status {
+			return kind.code
+		}
+	}
+	return "error"
+}
+
+// problemFor converts an error returned from an endpoint into a
+// Problem. ctx is the request's context, which is used to detect
+// timeouts and cancellations.
+func problemFor(ctx context.Context, err error) Problem {
+	var userErr APIUserError
+	if errors.As(err, &userErr) {
+		status := userErr.Status
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		code := userErr.Code
+		if code == "" {
+			code = codeForStatus(status)
+		}
+		return newProblem(status, code, userErr.Error())
+	}
+
+	var bccErr *bcc.Error
+	if errors.As(err, &bccErr) {
+		for _, kind := range errorKinds {
+			if errors.Is(bccErr, kind.err) {
+				return newProblem(kind.status, kind.code, bccErr.Msg)
+			}
+		}
+	}
+
+	switch ctxErr := ctx.Err(); {
+	case errors.Is(ctxErr, context.DeadlineExceeded):
+		return newProblem(http.StatusGatewayTimeout, "timeout", "request timed out")
+	case ctxErr != nil:
+		return newProblem(http.StatusServiceUnavailable, "canceled", "request canceled")
+	}
+
+	return newProblem(http.StatusInternalServerError, "internal", "internal server error")
+}
+
+// writeProblem sends p to the client.
+func writeProblem(ctx context.Context, rw http.ResponseWriter, p Problem) {
+	rw.Header().Set("Content-Type", "application/problem+json")
+	rw.Header().Set("X-Content-Type-Options", "nosniff")
+	rw.WriteHeader(p.Status)
+	errorsTotal.WithLabelValues(strconv.FormatInt(int64(p.Status), 10), p.Code).Inc()
+
+	err := json.NewEncoder(rw).Encode(p)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to send problem", "error", err)
+	}
+}
+
+// newRequestID returns a random ID for identifying a request.
+func newRequestID() string {
+	var buf [8]byte
+	_, err := rand.Read(buf[:])
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf[:])
+}
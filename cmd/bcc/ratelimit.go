@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DeedleFake/backend-code-challenge/internal/ratelimit"
+)
+
+// RateLimiter limits how often each client can make requests to each
+// endpoint. Every client gets a token bucket per endpoint. It is used
+// by both APIMux and GRPCServer.
+type RateLimiter struct {
+	// Store keeps the buckets. If it is shared between servers, so are
+	// the limits.
+	Store ratelimit.Store
+
+	// Limit applies to endpoints that aren't in Limits. The zero Limit
+	// doesn't limit them.
+	Limit ratelimit.Limit
+
+	// Limits overrides Limit for specific endpoints.
+	Limits map[APIMapping]ratelimit.Limit
+
+	// Clients identifies the clients that the buckets belong to.
+	Clients
+}
+
+// allow takes a token from the bucket for the client making req to the
+// endpoint at mapping and sets the RateLimit headers of the response.
+// If there wasn't a token to take, it fails the request and returns
+// false. A nil RateLimiter allows everything.
+func (rl *RateLimiter) allow(rw http.ResponseWriter, req *http.Request, mapping APIMapping) bool {
+	if rl == nil {
+		return true
+	}
+
+	client := rl.clientKey(req)
+	limit, r, ok := rl.take(req.Context(), mapping, client)
+	if !ok {
+		return true
+	}
+
+	setRateLimitHeaders(rw.Header(), limit, r)
+	if r.Allowed {
+		return true
+	}
+
+	addLogAttrs(req.Context(), slog.String("client", client))
+	fail(rw, req, newProblem(http.StatusTooManyRequests, "rate_limited", "too many requests"), nil)
+	return false
+}
+
+// take takes a token from the bucket for client and the endpoint at
+// mapping. If the endpoint isn't limited, ok is false.
+//
+// ok is also false if the Store fails, as refusing every request
+// because of a problem with the Store would be worse than not limiting
+// them for a while.
+func (rl *RateLimiter) take(ctx context.Context, mapping APIMapping, client string) (limit ratelimit.Limit, r ratelimit.Result, ok bool) {
+	limit, ok = rl.Limits[mapping]
+	if !ok {
+		limit = rl.Limit
+	}
+	if limit.IsZero() {
+		return limit, r, false
+	}
+
+	r, err := rl.Store.Take(ctx, mapping.Method+" "+mapping.Path+" "+client, limit)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to check rate limit", "error", err)
+		return limit, r, false
+	}
+	return limit, r, true
+}
+
+// setRateLimitHeaders sets the headers that describe the result r of
+// taking a token from a bucket with the given limit.
+func setRateLimitHeaders(h http.Header, limit ratelimit.Limit, r ratelimit.Result) {
+	policy := strconv.FormatInt(int64(limit.Tokens), 10) + ";w=" + seconds(limit.Per)
+	if limit.Burst != limit.Tokens {
+		policy += ";burst=" + strconv.FormatInt(int64(limit.Burst), 10)
+	}
+	h.Set("RateLimit-Policy", policy)
+	h.Set("RateLimit-Limit", strconv.FormatInt(int64(limit.Burst), 10))
+	h.Set("RateLimit-Remaining", strconv.FormatInt(int64(r.Remaining), 10))
+	h.Set("RateLimit-Reset", seconds(r.Reset))
+	if !r.Allowed {
+		h.Set("Retry-After", seconds(r.RetryAfter))
+	}
+}
+
+// seconds formats d as a whole number of seconds, rounding up, for use
+// in a header.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
@@ -1,21 +1,24 @@
 package main
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"net/http"
 
 	"github.com/DeedleFake/backend-code-challenge/bcc"
-	"github.com/jmoiron/sqlx"
 )
 
 type PostRatingParams struct {
-	UserID  uint64  `json:"user_id" desc:"ID of the user being rated"`
-	RaterID uint64  `json:"rater_id" desc:"ID of the user doing the rating"`
-	Rating  float64 `json:"rating" desc:"rating being given, must be between 1 and 5, inclusive"`
+	UserID  uint64  `json:"user_id" validate:"required" desc:"ID of the user being rated"`
+	RaterID uint64  `json:"rater_id" validate:"required" desc:"ID of the user doing the rating" log:"user_id"`
+	Rating  float64 `json:"rating" validate:"required,min=1,max=5" desc:"rating being given"`
 }
 
-type PostRatingHandler struct{}
+type PostRatingHandler struct {
+	// Policy is the set of anti-abuse rules that ratings are checked
+	// against.
+	Policy bcc.RatingPolicy
+}
 
 func (h PostRatingHandler) Desc() string {
 	return "rate a user"
@@ -25,16 +28,36 @@ func (h PostRatingHandler) Params() interface{} {
 	return &PostRatingParams{}
 }
 
-func (h PostRatingHandler) Serve(req *http.Request, db *sqlx.DB, params interface{}) (interface{}, error) {
+func (h PostRatingHandler) TxMode() TxMode {
+	// RateUser manages its own transaction so that rejected attempts
+	// are still recorded when the rating itself is rolled back.
+	return TxNone
+}
+
+func (h PostRatingHandler) Serve(req *http.Request, db bcc.DB, params interface{}) (interface{}, error) {
 	q := params.(*PostRatingParams)
-	if (q.Rating < 1) || (q.Rating > 5) {
-		return nil, BadRequest(errors.New("rating must be between 1 and 5, inclusive"))
-	}
 
-	err := bcc.RateUser(db, q.RaterID, q.UserID, q.Rating)
+	err := rateUser(req.Context(), db, h.Policy, q.RaterID, q.UserID, q.Rating)
 	if err != nil {
-		return nil, fmt.Errorf("rate user: %w", err)
+		return nil, err
 	}
 
 	return nil, nil
 }
+
+// rateUser rates a user and records the outcome in the rating metrics.
+func rateUser(ctx context.Context, db bcc.DB, policy bcc.RatingPolicy, raterID, userID uint64, rating float64) error {
+	event, err := bcc.RateUser(ctx, db, policy, raterID, userID, rating)
+	if reason, ok := bcc.RejectionReason(err); ok {
+		ratingsTotal.WithLabelValues("rejected_" + reason).Inc()
+	}
+	if err != nil {
+		return fmt.Errorf("rate user: %w", err)
+	}
+
+	ratingsTotal.WithLabelValues("accepted").Inc()
+	if event != nil {
+		ratingEventsTotal.Inc()
+	}
+	return nil
+}
@@ -1,18 +1,31 @@
 package main
 
 import (
-	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/DeedleFake/backend-code-challenge/bcc"
-	"github.com/jmoiron/sqlx"
 )
 
 type GetTimelineParams struct {
-	UserID uint64 `query:"user_id" desc:"ID of the user whose timeline is being fetched"`
-	Start  int    `query:"start" desc:"number of timeline entries to skip before returning results"`
-	Limit  int    `query:"limit" desc:"maximum number of results to return"`
+	UserID uint64 `query:"user_id" validate:"required" desc:"ID of the user whose timeline is being fetched" log:"user_id"`
+	Start  int    `query:"start" validate:"min=0" desc:"number of timeline entries to skip before returning results"`
+	Limit  int    `query:"limit" validate:"min=0,max=100" desc:"maximum number of results to return"`
+}
+
+// TimelineResponse is the response to a GET /timeline request.
+type TimelineResponse []bcc.TimelineEntry
+
+// LastModified returns the latest time at which any of the entries
+// was updated.
+func (rsp TimelineResponse) LastModified() (last time.Time) {
+	for _, e := range rsp {
+		if e.UpdatedAt.After(last) {
+			last = e.UpdatedAt
+		}
+	}
+	return last
 }
 
 type GetTimelineHandler struct{}
@@ -27,26 +40,97 @@ func (h GetTimelineHandler) Params() interface{} {
 	}
 }
 
-func (h GetTimelineHandler) Serve(req *http.Request, db *sqlx.DB, params interface{}) (interface{}, error) {
+func (h GetTimelineHandler) Response() interface{} {
+	return TimelineResponse{}
+}
+
+// Validator lets conditional requests for pages of the timeline that
+// are still cached be answered without reading them.
+func (h GetTimelineHandler) Validator(req *http.Request, db bcc.DB, params interface{}) (string, bool) {
 	q := params.(*GetTimelineParams)
-	if q.Limit > 100 {
-		return nil, BadRequest(errors.New("limit must not be larger than 100"))
-	}
+	return bcc.TimelineVersion(req.Context(), db, q.UserID, q.Start, q.Limit)
+}
 
-	entries, err := bcc.GetTimeline(db, q.UserID, q.Start, q.Limit)
+func (h GetTimelineHandler) Serve(req *http.Request, db bcc.DB, params interface{}) (interface{}, error) {
+	q := params.(*GetTimelineParams)
+
+	start := time.Now()
+	defer func() { timelineDuration.Observe(time.Since(start).Seconds()) }()
+
+	entries, err := bcc.Timeline(req.Context(), db, q.UserID, q.Start, q.Limit)
 	if err != nil {
 		return nil, fmt.Errorf("get timeline: %w", err)
 	}
-	defer entries.Close()
 
-	results := []bcc.TimelineEntry{}
-	for entries.Next() {
-		entry := entries.Current().(bcc.TimelineEntry)
-		results = append(results, entry)
-	}
-	if err := entries.Err(); err != nil {
+	results, err := bcc.Collect(entries, q.Limit)
+	if err != nil {
 		return nil, fmt.Errorf("iteration: %w", err)
 	}
 
-	return results, nil
+	return TimelineResponse(results), nil
+}
+
+// TimelineEntryV2 is a timeline entry serialized as a tagged union.
+// Type says which one of the other fields is set.
+type TimelineEntryV2 struct {
+	Type string `json:"type"`
+
+	Post            *bcc.PostEntry            `json:"post,omitempty"`
+	Comment         *bcc.CommentEntry         `json:"comment,omitempty"`
+	RatingMilestone *bcc.RatingMilestoneEntry `json:"rating_milestone,omitempty"`
+	GitHubEvent     *bcc.GitHubEntry          `json:"github_event,omitempty"`
+
+	entry bcc.Entry
+}
+
+// unionTag documents TimelineEntryV2 as a tagged union in the OpenAPI
+// document.
+func (TimelineEntryV2) unionTag() string {
+	return "type"
+}
+
+// newTimelineEntryV2 wraps e in a TimelineEntryV2.
+func newTimelineEntryV2(e bcc.Entry) TimelineEntryV2 {
+	v := TimelineEntryV2{Type: e.EntryType(), entry: e}
+	switch e := e.(type) {
+	case *bcc.PostEntry:
+		v.Post = e
+	case *bcc.CommentEntry:
+		v.Comment = e
+	case *bcc.RatingMilestoneEntry:
+		v.RatingMilestone = e
+	case *bcc.GitHubEntry:
+		v.GitHubEvent = e
+	}
+	return v
+}
+
+// TimelineResponseV2 is the response to a GET /timeline request in
+// version 2 of the API.
+type TimelineResponseV2 []TimelineEntryV2
+
+// LastModified returns the latest time at which any of the entries
+// was updated.
+func (rsp TimelineResponseV2) LastModified() (last time.Time) {
+	for _, e := range rsp {
+		if updated := e.entry.Header().UpdatedAt; updated.After(last) {
+			last = updated
+		}
+	}
+	return last
+}
+
+// timelineV2 converts a TimelineResponse into a TimelineResponseV2.
+// It is used to serve GET /timeline in version 2 of the API.
+func timelineV2(rsp interface{}) (interface{}, error) {
+	entries := rsp.(TimelineResponse)
+	v2 := make(TimelineResponseV2, 0, len(entries))
+	for _, e := range entries {
+		entry, err := e.Typed()
+		if err != nil {
+			return nil, err
+		}
+		v2 = append(v2, newTimelineEntryV2(entry))
+	}
+	return v2, nil
 }
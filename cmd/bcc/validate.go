@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// FieldError describes a parameter that failed validation.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// fieldRules are the rules parsed from a validate tag. The tag is a
+// comma-separated list of the following:
+//
+//	required     the value must not be the zero value
+//	min=N        numbers must be at least N
+//	max=N        numbers must be at most N
+//	minlen=N     strings must be at least N characters long, and lists
+//	             must have at least N items
+//	maxlen=N     strings must be at most N characters long, and lists
+//	             must have at most N items
+//	enum=a|b|c   the value must be one of the listed values
+//	pattern=re   strings must match the regular expression re
+//
+// Because regular expressions may contain commas, pattern must be the
+// last rule in the tag.
+type fieldRules struct {
+	Required       bool
+	Min, Max       *float64
+	MinLen, MaxLen *int
+	Enum           []string
+	Pattern        *regexp.Regexp
+}
+
+var rulesCache sync.Map // map[string]fieldRules
+
+// parseRules parses a validate tag. Results are cached, as the same
+// tags are checked on every request.
+func parseRules(tag string) (fieldRules, error) {
+	if r, ok := rulesCache.Load(tag); ok {
+		return r.(fieldRules), nil
+	}
+
+	var r fieldRules
+	for rest := tag; rest != ""; {
+		var rule string
+		if strings.HasPrefix(rest, "pattern=") {
+			rule, rest = rest, ""
+		} else {
+			rule, rest, _ = strings.Cut(rest, ",")
+		}
+
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			r.Required = true
+
+		case "min", "max":
+			n, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return r, fmt.Errorf("parse %q: %w", rule, err)
+			}
+			if name == "min" {
+				r.Min = &n
+			} else {
+				r.Max = &n
+			}
+
+		case "minlen", "maxlen":
+			n, err := strconv.Atoi(arg)
+			if err != nil {
+				return r, fmt.Errorf("parse %q: %w", rule, err)
+			}
+			if name == "minlen" {
+				r.MinLen = &n
+			} else {
+				r.MaxLen = &n
+			}
+
+		case "enum":
+			r.Enum = strings.Split(arg, "|")
+
+		case "pattern":
+			re, err := regexp.Compile(arg)
+			if err != nil {
+				return r, fmt.Errorf("parse %q: %w", rule, err)
+			}
+			r.Pattern = re
+
+		default:
+			return r, fmt.Errorf("unknown rule %q", rule)
+		}
+	}
+
+	rulesCache.Store(tag, r)
+	return r, nil
+}
+
+// check returns a message for every rule that v breaks.
+func (r fieldRules) check(v reflect.Value) (msgs []string) {
+	if v.IsZero() {
+		if r.Required {
+			msgs = append(msgs, "is required")
+		}
+		return msgs
+	}
+
+	var num *float64
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := float64(v.Int())
+		num = &n
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n := float64(v.Uint())
+		num = &n
+	case reflect.Float32, reflect.Float64:
+		n := v.Float()
+		num = &n
+	}
+	if (num != nil) && (r.Min != nil) && (*num < *r.Min) {
+		msgs = append(msgs, fmt.Sprintf("must be at least %v", *r.Min))
+	}
+	if (num != nil) && (r.Max != nil) && (*num > *r.Max) {
+		msgs = append(msgs, fmt.Sprintf("must be at most %v", *r.Max))
+	}
+
+	if v.Kind() == reflect.String {
+		s := v.String()
+		if n := utf8.RuneCountInString(s); (r.MinLen != nil) && (n < *r.MinLen) {
+			msgs = append(msgs, fmt.Sprintf("must be at least %v characters long", *r.MinLen))
+		}
+		if n := utf8.RuneCountInString(s); (r.MaxLen != nil) && (n > *r.MaxLen) {
+			msgs = append(msgs, fmt.Sprintf("must be at most %v characters long", *r.MaxLen))
+		}
+		if (r.Pattern != nil) && !r.Pattern.MatchString(s) {
+			msgs = append(msgs, fmt.Sprintf("must match %q", r.Pattern))
+		}
+	}
+
+	if v.Kind() == reflect.Slice {
+		if n := v.Len(); (r.MinLen != nil) && (n < *r.MinLen) {
+			msgs = append(msgs, fmt.Sprintf("must have at least %v items", *r.MinLen))
+		}
+		if n := v.Len(); (r.MaxLen != nil) && (n > *r.MaxLen) {
+			msgs = append(msgs, fmt.Sprintf("must have at most %v items", *r.MaxLen))
+		}
+	}
+
+	if (r.Enum != nil) && !slices.Contains(r.Enum, fmt.Sprint(v.Interface())) {
+		msgs = append(msgs, fmt.Sprintf("must be one of %v", strings.Join(r.Enum, ", ")))
+	}
+
+	return msgs
+}
+
+// fieldName returns the name of a parameter as it appears in the
+// request. tag is either "query" or "json".
+func fieldName(f reflect.StructField, tag string) string {
+	name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// validate checks every field of the struct pointed to by params
+// against the rules in its validate tag. Fields are named using tag.
+// It returns an error only if a validate tag is malformed.
+func validate(params interface{}, tag string) ([]FieldError, error) {
+	v := reflect.Indirect(reflect.ValueOf(params))
+
+	var errs []FieldError
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+
+		vt, ok := f.Tag.Lookup("validate")
+		if !ok {
+			continue
+		}
+		rules, err := parseRules(vt)
+		if err != nil {
+			return nil, fmt.Errorf("field %v: %w", f.Name, err)
+		}
+
+		name := fieldName(f, tag)
+		for _, msg := range rules.check(v.Field(i)) {
+			errs = append(errs, FieldError{
+				Field:   name,
+				Message: fmt.Sprintf("%v %v", name, msg),
+			})
+		}
+	}
+
+	return errs, nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		tag     string
+		req     bool
+		min     *float64
+		max     *float64
+		minLen  *int
+		maxLen  *int
+		enum    []string
+		pattern string
+		err     bool
+	}{
+		{tag: "required", req: true},
+		{tag: "min=1,max=5", min: ptr(1.0), max: ptr(5.0)},
+		{tag: "min=-2.5", min: ptr(-2.5)},
+		{tag: "required,minlen=1,maxlen=50", req: true, minLen: ptr(1), maxLen: ptr(50)},
+		{tag: "enum=a|b|c", enum: []string{"a", "b", "c"}},
+		{tag: "required,pattern=^[a-z]{1,3},x$", req: true, pattern: "^[a-z]{1,3},x$"},
+		{tag: "min=one", err: true},
+		{tag: "maxlen=1.5", err: true},
+		{tag: "pattern=(", err: true},
+		{tag: "unknown", err: true},
+		{tag: "pattern=a,required", pattern: "a,required"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.tag, func(t *testing.T) {
+			r, err := parseRules(test.tag)
+			if test.err {
+				if err == nil {
+					t.Fatal("no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if r.Required != test.req {
+				t.Errorf("Required is %v, want %v", r.Required, test.req)
+			}
+			if !reflect.DeepEqual(r.Min, test.min) || !reflect.DeepEqual(r.Max, test.max) {
+				t.Errorf("Min and Max are %v and %v, want %v and %v", deref(r.Min), deref(r.Max), deref(test.min), deref(test.max))
+			}
+			if !reflect.DeepEqual(r.MinLen, test.minLen) || !reflect.DeepEqual(r.MaxLen, test.maxLen) {
+				t.Errorf("MinLen and MaxLen are %v and %v, want %v and %v", deref(r.MinLen), deref(r.MaxLen), deref(test.minLen), deref(test.maxLen))
+			}
+			if !slices.Equal(r.Enum, test.enum) {
+				t.Errorf("Enum is %q, want %q", r.Enum, test.enum)
+			}
+			var pattern string
+			if r.Pattern != nil {
+				pattern = r.Pattern.String()
+			}
+			if pattern != test.pattern {
+				t.Errorf("Pattern is %q, want %q", pattern, test.pattern)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	type params struct {
+		ID     uint64   `query:"id" json:"id" validate:"required"`
+		Rating float64  `query:"rating" json:"rating" validate:"min=1,max=5"`
+		Name   string   `query:"name" json:"display_name" validate:"minlen=2,maxlen=4"`
+		Sort   string   `query:"sort" validate:"enum=asc|desc"`
+		Code   string   `query:"code" validate:"pattern=^[A-Z]+$"`
+		Tags   []string `query:"tags" validate:"minlen=1,maxlen=2"`
+		Extra  string   `query:"extra"`
+	}
+
+	tests := []struct {
+		name   string
+		params params
+		tag    string
+		errs   []FieldError
+	}{
+		{
+			name:   "Valid",
+			params: params{ID: 1, Rating: 3, Name: "abc", Sort: "asc", Code: "AB", Tags: []string{"a"}},
+			tag:    "query",
+		},
+		{
+			name:   "ZeroOptional",
+			params: params{ID: 1},
+			tag:    "query",
+		},
+		{
+			name:   "Required",
+			params: params{},
+			tag:    "query",
+			errs:   []FieldError{{Field: "id", Message: "id is required"}},
+		},
+		{
+			name:   "Range",
+			params: params{ID: 1, Rating: 6},
+			tag:    "query",
+			errs:   []FieldError{{Field: "rating", Message: "rating must be at most 5"}},
+		},
+		{
+			name:   "LengthCountsCharacters",
+			params: params{ID: 1, Name: "ééééé"},
+			tag:    "json",
+			errs:   []FieldError{{Field: "display_name", Message: "display_name must be at most 4 characters long"}},
+		},
+		{
+			name:   "ShortString",
+			params: params{ID: 1, Name: "é"},
+			tag:    "query",
+			errs:   []FieldError{{Field: "name", Message: "name must be at least 2 characters long"}},
+		},
+		{
+			name:   "Enum",
+			params: params{ID: 1, Sort: "up"},
+			tag:    "query",
+			errs:   []FieldError{{Field: "sort", Message: "sort must be one of asc, desc"}},
+		},
+		{
+			name:   "Pattern",
+			params: params{ID: 1, Code: "ab"},
+			tag:    "query",
+			errs:   []FieldError{{Field: "code", Message: `code must match "^[A-Z]+$"`}},
+		},
+		{
+			name:   "ItemCount",
+			params: params{ID: 1, Tags: []string{"a", "b", "c"}},
+			tag:    "query",
+			errs:   []FieldError{{Field: "tags", Message: "tags must have at most 2 items"}},
+		},
+		{
+			name:   "FieldNameFallsBackToGoName",
+			params: params{ID: 1, Sort: "up"},
+			tag:    "json",
+			errs:   []FieldError{{Field: "Sort", Message: "Sort must be one of asc, desc"}},
+		},
+		{
+			name:   "Several",
+			params: params{Rating: 0.5, Code: "a"},
+			tag:    "query",
+			errs: []FieldError{
+				{Field: "id", Message: "id is required"},
+				{Field: "rating", Message: "rating must be at least 1"},
+				{Field: "code", Message: `code must match "^[A-Z]+$"`},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			errs, err := validate(&test.params, test.tag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(errs, test.errs) {
+				t.Errorf("got %v, want %v", errs, test.errs)
+			}
+		})
+	}
+}
+
+func TestValidateMalformedTag(t *testing.T) {
+	params := struct {
+		N int `validate:"min=x"`
+	}{}
+
+	_, err := validate(&params, "query")
+	if err == nil {
+		t.Fatal("no error")
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func deref[T any](p *T) interface{} {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
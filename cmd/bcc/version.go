@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DeedleFake/backend-code-challenge/bcc"
+)
+
+// APIVersion is a version of the API. Requests select a version either
+// with a path prefix, such as /v2/timeline, or with an API-Version
+// header, such as "API-Version: 2". The version that was used is sent
+// back in the API-Version header of the response.
+type APIVersion struct {
+	// Number identifies the version. Versions are numbered from 1.
+	Number int
+
+	// Deprecated is when the version was deprecated. If it isn't the
+	// zero time, responses in this version carry a Deprecation header.
+	Deprecated time.Time
+
+	// Sunset is when the version will stop being served. If it isn't
+	// the zero time, responses in this version carry a Sunset header.
+	Sunset time.Time
+
+	// Link, if not empty, is the URL of documentation about the
+	// deprecation. It is sent in a Link header.
+	Link string
+}
+
+func (v APIVersion) String() string {
+	return "v" + strconv.FormatInt(int64(v.Number), 10)
+}
+
+// setHeaders sets the headers that describe v on a response.
+func (v APIVersion) setHeaders(h http.Header) {
+	h.Set("API-Version", strconv.FormatInt(int64(v.Number), 10))
+	h.Add("Vary", "API-Version")
+	if !v.Deprecated.IsZero() {
+		h.Set("Deprecation", "@"+strconv.FormatInt(v.Deprecated.Unix(), 10))
+	}
+	if !v.Sunset.IsZero() {
+		h.Set("Sunset", v.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if v.Link != "" {
+		h.Add("Link", fmt.Sprintf("<%v>; rel=\"deprecation\"", v.Link))
+	}
+}
+
+type apiVersionKey struct{}
+
+// apiVersion returns the version of the API that the request that ctx
+// belongs to is being served in.
+func apiVersion(ctx context.Context) APIVersion {
+	v, _ := ctx.Value(apiVersionKey{}).(APIVersion)
+	return v
+}
+
+// APIVersioner is implemented by APIEndpoints that are served
+// differently in different versions of the API.
+type APIVersioner interface {
+	// ForVersion returns the endpoint that serves the given version,
+	// or nil if the endpoint doesn't exist in that version.
+	ForVersion(version int) APIEndpoint
+}
+
+// VersionedEndpoint is an APIEndpoint that changed in some versions of
+// the API. It maps version numbers to the endpoints that were
+// introduced in them. A version is served by the endpoint introduced
+// in it or, if there isn't one, the latest one introduced before it.
+// The endpoint doesn't exist in versions before the first one in the
+// map.
+//
+// Its APIEndpoint methods describe the endpoint of the latest version.
+type VersionedEndpoint map[int]APIEndpoint
+
+func (ve VersionedEndpoint) ForVersion(version int) APIEndpoint {
+	best := 0
+	for n := range ve {
+		if (n <= version) && (n > best) {
+			best = n
+		}
+	}
+	return ve[best]
+}
+
+// versions returns the versions in ve, oldest first.
+func (ve VersionedEndpoint) versions() []int {
+	versions := make([]int, 0, len(ve))
+	for n := range ve {
+		versions = append(versions, n)
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+func (ve VersionedEndpoint) latest() APIEndpoint {
+	versions := ve.versions()
+	return ve[versions[len(versions)-1]]
+}
+
+func (ve VersionedEndpoint) Desc() string {
+	return ve.latest().Desc()
+}
+
+func (ve VersionedEndpoint) Params() interface{} {
+	return ve.latest().Params()
+}
+
+func (ve VersionedEndpoint) Serve(req *http.Request, db bcc.DB, params interface{}) (interface{}, error) {
+	return ve.latest().Serve(req, db, params)
+}
+
+// TransformResponse returns an endpoint that serves requests the same
+// way as h but converts its responses with transform before they are
+// sent. If transform returns an error, it is handled like any other
+// error from h. example is a value of the type that transform returns,
+// which is used to document the endpoint.
+func TransformResponse(h APIEndpoint, transform func(rsp interface{}) (interface{}, error), example interface{}) APIEndpoint {
+	return transformedEndpoint{
+		APIEndpoint: h,
+		transform:   transform,
+		example:     example,
+	}
+}
+
+// transformedEndpoint forwards the optional interfaces of the
+// endpoint that it wraps.
+type transformedEndpoint struct {
+	APIEndpoint
+	transform func(interface{}) (interface{}, error)
+	example   interface{}
+}
+
+func (h transformedEndpoint) Response() interface{} {
+	return h.example
+}
+
+func (h transformedEndpoint) TxMode() TxMode {
+	if t, ok := h.APIEndpoint.(APITransactor); ok {
+		return t.TxMode()
+	}
+	return TxNone
+}
+
+func (h transformedEndpoint) Validator(req *http.Request, db bcc.DB, params interface{}) (string, bool) {
+	if v, ok := h.APIEndpoint.(APIValidator); ok {
+		return v.Validator(req, db, params)
+	}
+	return "", false
+}
+
+func (h transformedEndpoint) Middleware() []Middleware {
+	if m, ok := h.APIEndpoint.(APIMiddlewarer); ok {
+		return m.Middleware()
+	}
+	return nil
+}
+
+func (h transformedEndpoint) Serve(req *http.Request, db bcc.DB, params interface{}) (interface{}, error) {
+	rsp, err := h.APIEndpoint.Serve(req, db, params)
+	if (err != nil) || (rsp == nil) {
+		return rsp, err
+	}
+	return h.transform(rsp)
+}
+
+type apiVersionRequestKey struct{}
+
+// version finds the version of the API that req asks for and strips
+// the version's prefix from the path if there is one. The version is
+// checked by route, so that requests for unknown versions are handled
+// like any other failed request.
+func (mux APIMux) version(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requested := req.Header.Get("API-Version")
+		if n, rest, ok := splitVersion(req.URL.Path); ok {
+			requested = n
+			req = req.Clone(req.Context())
+			req.URL.Path = rest
+			req.URL.RawPath = ""
+		}
+
+		ctx := context.WithValue(req.Context(), apiVersionRequestKey{}, requested)
+		next.ServeHTTP(rw, req.WithContext(ctx))
+	})
+}
+
+// findVersion returns the version of the API that the request that ctx
+// belongs to asked for, or DefaultVersion if it didn't ask for one.
+func (mux APIMux) findVersion(ctx context.Context) (APIVersion, error) {
+	requested, _ := ctx.Value(apiVersionRequestKey{}).(string)
+
+	number := mux.DefaultVersion
+	if requested != "" {
+		n, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(requested), "v"), 10, 31)
+		if err != nil {
+			return APIVersion{}, fmt.Errorf("invalid API version %q", requested)
+		}
+		number = int(n)
+	}
+
+	i := sort.Search(len(mux.Versions), func(i int) bool { return mux.Versions[i].Number >= number })
+	if (i == len(mux.Versions)) || (mux.Versions[i].Number != number) {
+		return APIVersion{}, fmt.Errorf("unknown API version %v", number)
+	}
+	return mux.Versions[i], nil
+}
+
+// splitVersion splits a path such as /v2/timeline into its version,
+// such as v2, and the rest of the path.
+func splitVersion(path string) (version, rest string, ok bool) {
+	version, rest, ok = strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if !ok || (len(version) < 2) || (version[0] != 'v') {
+		return "", "", false
+	}
+	for _, c := range version[1:] {
+		if (c < '0') || (c > '9') {
+			return "", "", false
+		}
+	}
+	return version, "/" + rest, true
+}
+
+// docEndpoints returns a copy of endpoints for documentation purposes
+// in which each VersionedEndpoint is also listed under the prefixed
+// path of every version that it was changed in. Unprefixed paths are
+// documented as they are served in defaultVersion.
+func docEndpoints(endpoints map[APIMapping]APIEndpoint, defaultVersion int) map[APIMapping]APIEndpoint {
+	docs := make(map[APIMapping]APIEndpoint, len(endpoints))
+	for m, h := range endpoints {
+		ve, ok := h.(VersionedEndpoint)
+		if !ok {
+			docs[m] = h
+			continue
+		}
+
+		if d := ve.ForVersion(defaultVersion); d != nil {
+			docs[m] = d
+		}
+		for _, n := range ve.versions() {
+			docs[APIMapping{Method: m.Method, Path: fmt.Sprintf("/v%v%v", n, m.Path)}] = ve[n]
+		}
+	}
+	return docs
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSplitVersion(t *testing.T) {
+	tests := []struct {
+		path    string
+		version string
+		rest    string
+		ok      bool
+	}{
+		{path: "/v2/timeline", version: "v2", rest: "/timeline", ok: true},
+		{path: "/v10/post/comments", version: "v10", rest: "/post/comments", ok: true},
+		{path: "/v1/", version: "v1", rest: "/", ok: true},
+		{path: "/timeline", ok: false},
+		{path: "/v2", ok: false},
+		{path: "/v/timeline", ok: false},
+		{path: "/vx/timeline", ok: false},
+		{path: "/v2a/timeline", ok: false},
+		{path: "/version/timeline", ok: false},
+		{path: "/2/timeline", ok: false},
+		{path: "", ok: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			version, rest, ok := splitVersion(test.path)
+			if (version != test.version) || (rest != test.rest) || (ok != test.ok) {
+				t.Errorf("got %q, %q, %v, want %q, %q, %v", version, rest, ok, test.version, test.rest, test.ok)
+			}
+		})
+	}
+}
+
+func TestFindVersion(t *testing.T) {
+	mux := APIMux{
+		Versions:       []APIVersion{{Number: 1}, {Number: 2}, {Number: 4}},
+		DefaultVersion: 2,
+	}
+
+	tests := []struct {
+		requested string
+		number    int
+		err       bool
+	}{
+		{requested: "", number: 2},
+		{requested: "1", number: 1},
+		{requested: "v4", number: 4},
+		{requested: "V1", number: 1},
+		{requested: "3", err: true},
+		{requested: "5", err: true},
+		{requested: "0", err: true},
+		{requested: "-1", err: true},
+		{requested: "two", err: true},
+		{requested: "99999999999", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.requested, func(t *testing.T) {
+			ctx := context.WithValue(context.Background(), apiVersionRequestKey{}, test.requested)
+			v, err := mux.findVersion(ctx)
+			if test.err {
+				if err == nil {
+					t.Fatalf("got %v, want an error", v)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v.Number != test.number {
+				t.Errorf("got %v, want %v", v.Number, test.number)
+			}
+		})
+	}
+}
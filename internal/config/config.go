@@ -0,0 +1,128 @@
+// Package config loads the configuration of the bcc commands.
+//
+// Settings are defined as flags, so every setting has a flag. Each can
+// also be given in a JSON config file, whose path is given with the
+// -config flag, or in an environment variable. The config file is an
+// object mapping flag names to values, such as
+//
+//	{"dbaddr": "db.example.com", "dbmaxopen": 20}
+//
+// and environment variables are named by prefixing the flag's name
+// with BCC_, uppercasing it, and replacing dashes with underscores,
+// such as BCC_DBPASS_FILE for -dbpass-file. Flags take precedence over
+// environment variables, which take precedence over the config file.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Validator is implemented by configuration sections that can check
+// themselves for errors once they have been loaded.
+type Validator interface {
+	Validate() error
+}
+
+// EnvName returns the name of the environment variable that sets the
+// flag with the given name.
+func EnvName(flag string) string {
+	return "BCC_" + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// Load adds a -config flag to fs, parses args with it, and then fills
+// in any flags that weren't given explicitly from the environment and
+// from the config file. Finally, it validates each of sections.
+func Load(fs *flag.FlagSet, args []string, sections ...Validator) error {
+	path := fs.String("config", os.Getenv(EnvName("config")), "path to a JSON config file")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	if *path != "" {
+		err := loadFile(fs, *path, set)
+		if err != nil {
+			return fmt.Errorf("load %q: %w", *path, err)
+		}
+	}
+
+	err = loadEnv(fs, set)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, s := range sections {
+		errs = append(errs, s.Validate())
+	}
+	return errors.Join(errs...)
+}
+
+// loadFile sets the flags in fs that aren't in set from the config
+// file at path.
+func loadFile(fs *flag.FlagSet, path string, set map[string]bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var values map[string]json.RawMessage
+	err = json.Unmarshal(data, &values)
+	if err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+
+	for name, raw := range values {
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("unknown setting %q", name)
+		}
+		if set[name] {
+			continue
+		}
+
+		// Strings are used as is, but anything else, such as numbers
+		// and bools, is given to the flag the way that it was written.
+		var val string
+		if json.Unmarshal(raw, &val) != nil {
+			val = string(raw)
+		}
+
+		err := fs.Set(name, val)
+		if err != nil {
+			return fmt.Errorf("%v: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// loadEnv sets the flags in fs that aren't in set from environment
+// variables. Flags that are set this way are added to set.
+func loadEnv(fs *flag.FlagSet, set map[string]bool) (err error) {
+	fs.VisitAll(func(f *flag.Flag) {
+		if (err != nil) || set[f.Name] {
+			return
+		}
+
+		val, ok := os.LookupEnv(EnvName(f.Name))
+		if !ok {
+			return
+		}
+
+		serr := fs.Set(f.Name, val)
+		if serr != nil {
+			err = fmt.Errorf("%v: %w", EnvName(f.Name), serr)
+			return
+		}
+		set[f.Name] = true
+	})
+	return err
+}
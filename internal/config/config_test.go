@@ -0,0 +1,172 @@
+package config_test
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DeedleFake/backend-code-challenge/internal/config"
+)
+
+type testSection struct {
+	Name  string
+	Count int
+	err   error
+}
+
+func (s *testSection) Validate() error {
+	return s.err
+}
+
+func (s *testSection) register(fs *flag.FlagSet) {
+	fs.StringVar(&s.Name, "test-name", "default", "")
+	fs.IntVar(&s.Count, "count", 1, "")
+}
+
+func TestEnvName(t *testing.T) {
+	tests := []struct {
+		flag string
+		env  string
+	}{
+		{"dbaddr", "BCC_DBADDR"},
+		{"dbpass-file", "BCC_DBPASS_FILE"},
+		{"config", "BCC_CONFIG"},
+	}
+
+	for _, test := range tests {
+		if env := config.EnvName(test.flag); env != test.env {
+			t.Errorf("EnvName(%q) = %q, want %q", test.flag, env, test.env)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+		want testSection
+		err  string
+	}{
+		{
+			name: "Defaults",
+			want: testSection{Name: "default", Count: 1},
+		},
+		{
+			name: "File",
+			file: `{"test-name": "file", "count": 2}`,
+			want: testSection{Name: "file", Count: 2},
+		},
+		{
+			name: "EnvOverFile",
+			file: `{"test-name": "file", "count": 2}`,
+			env:  map[string]string{"BCC_TEST_NAME": "env"},
+			want: testSection{Name: "env", Count: 2},
+		},
+		{
+			name: "FlagOverEnvAndFile",
+			file: `{"test-name": "file", "count": 2}`,
+			env:  map[string]string{"BCC_TEST_NAME": "env", "BCC_COUNT": "3"},
+			args: []string{"-test-name", "flag"},
+			want: testSection{Name: "flag", Count: 3},
+		},
+		{
+			name: "FlagSetToDefault",
+			env:  map[string]string{"BCC_TEST_NAME": "env"},
+			args: []string{"-test-name", "default"},
+			want: testSection{Name: "default", Count: 1},
+		},
+		{
+			name: "UnknownSetting",
+			file: `{"unknown": 1}`,
+			err:  `unknown setting "unknown"`,
+		},
+		{
+			name: "InvalidFileValue",
+			file: `{"count": "many"}`,
+			err:  "count:",
+		},
+		{
+			name: "InvalidEnvValue",
+			env:  map[string]string{"BCC_COUNT": "many"},
+			err:  "BCC_COUNT:",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("BCC_CONFIG", "")
+			for k, v := range test.env {
+				t.Setenv(k, v)
+			}
+
+			args := test.args
+			if test.file != "" {
+				path := filepath.Join(t.TempDir(), "config.json")
+				err := os.WriteFile(path, []byte(test.file), 0600)
+				if err != nil {
+					t.Fatal(err)
+				}
+				args = append([]string{"-config", path}, args...)
+			}
+
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			var s testSection
+			s.register(fs)
+
+			err := config.Load(fs, args, &s)
+			if test.err != "" {
+				if (err == nil) || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("error is %v, want one containing %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (s.Name != test.want.Name) || (s.Count != test.want.Count) {
+				t.Errorf("got %+v, want %+v", s, test.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFromEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(path, []byte(`{"count": 4}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("BCC_CONFIG", path)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	var s testSection
+	s.register(fs)
+
+	err = config.Load(fs, nil, &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Count != 4 {
+		t.Errorf("Count is %v, want 4", s.Count)
+	}
+}
+
+func TestLoadValidates(t *testing.T) {
+	errA := errors.New("a")
+	errB := errors.New("b")
+	a := &testSection{err: errA}
+	b := &testSection{err: errB}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	a.register(fs)
+
+	err := config.Load(fs, nil, a, b)
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Fatalf("error is %v, want one that wraps both sections' errors", err)
+	}
+}
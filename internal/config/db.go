@@ -0,0 +1,194 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DB configures the connection to the database.
+type DB struct {
+	// DSN, if not empty, is used as is to connect to the database and
+	// overrides all of the other connection settings.
+	DSN string
+
+	Addr         string
+	User         string
+	Password     string
+	PasswordFile string
+	Name         string
+
+	// SSLMode is one of the sslmode values supported by lib/pq, such as
+	// disable, require, verify-ca, or verify-full.
+	SSLMode     string
+	SSLRootCert string
+	SSLCert     string
+	SSLKey      string
+
+	ConnectTimeout time.Duration
+
+	// Replicas are read replicas of the database. Each is either an
+	// address, in which case the rest of the connection settings are
+	// the same as for the primary, or a full connection string.
+	Replicas []string
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// RegisterFlags adds flags to fs that set the fields of c.
+func (c *DB) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.DSN, "dsn", "", "full database connection string, overriding the other database settings")
+	fs.StringVar(&c.Addr, "dbaddr", "localhost", "database address")
+	fs.StringVar(&c.User, "dbuser", "postgres", "database user")
+	fs.StringVar(&c.Password, "dbpass", "", "database password; prefer -dbpass-file or the environment so that it isn't visible in process listings")
+	fs.StringVar(&c.PasswordFile, "dbpass-file", "", "file to read the database password from")
+	fs.StringVar(&c.Name, "dbname", "bcc", "database name")
+	fs.StringVar(&c.SSLMode, "dbsslmode", "disable", "database SSL `mode`: disable, require, verify-ca, or verify-full")
+	fs.StringVar(&c.SSLRootCert, "dbsslrootcert", "", "file containing the certificate authorities to verify the database server with")
+	fs.StringVar(&c.SSLCert, "dbsslcert", "", "client certificate file to present to the database server")
+	fs.StringVar(&c.SSLKey, "dbsslkey", "", "key file for -dbsslcert")
+	fs.DurationVar(&c.ConnectTimeout, "dbconnect-timeout", 0, "maximum time to wait when connecting to the database, 0 for no limit")
+	fs.Func("dbreplicas", "comma-separated list of addresses or connection strings of read replicas of the database", func(val string) error {
+		c.Replicas = nil
+		for _, r := range strings.Split(val, ",") {
+			if r = strings.TrimSpace(r); r != "" {
+				c.Replicas = append(c.Replicas, r)
+			}
+		}
+		return nil
+	})
+	fs.IntVar(&c.MaxOpenConns, "dbmaxopen", 0, "maximum number of open database connections, 0 for no limit")
+	fs.IntVar(&c.MaxIdleConns, "dbmaxidle", 2, "maximum number of idle database connections to keep")
+	fs.DurationVar(&c.ConnMaxLifetime, "dbconnlifetime", 0, "maximum time to reuse a database connection for, 0 for no limit")
+	fs.DurationVar(&c.ConnMaxIdleTime, "dbconnidletime", 0, "maximum time to keep an idle database connection for, 0 for no limit")
+}
+
+func (c *DB) Validate() error {
+	var errs []error
+	if (c.Password != "") && (c.PasswordFile != "") {
+		errs = append(errs, errors.New("only one of dbpass and dbpass-file may be set"))
+	}
+	if !slices.Contains([]string{"disable", "require", "verify-ca", "verify-full"}, c.SSLMode) {
+		errs = append(errs, fmt.Errorf("unknown dbsslmode %q", c.SSLMode))
+	}
+	if (c.SSLCert == "") != (c.SSLKey == "") {
+		errs = append(errs, errors.New("dbsslcert and dbsslkey must be set together"))
+	}
+	if (c.DSN != "") && slices.ContainsFunc(c.Replicas, func(r string) bool { return !strings.Contains(r, "://") }) {
+		errs = append(errs, errors.New("dbreplicas must be full connection strings when dsn is set"))
+	}
+	if c.MaxOpenConns < 0 {
+		errs = append(errs, errors.New("dbmaxopen must not be negative"))
+	}
+	if c.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("dbmaxidle must not be negative"))
+	}
+	if (c.MaxOpenConns > 0) && (c.MaxIdleConns > c.MaxOpenConns) {
+		errs = append(errs, errors.New("dbmaxidle must not be greater than dbmaxopen"))
+	}
+	return errors.Join(errs...)
+}
+
+// DataSource returns the connection string for the primary database.
+// If a password file was given, it is read.
+func (c *DB) DataSource() (string, error) {
+	if c.DSN != "" {
+		return c.DSN, nil
+	}
+	return c.dataSource(c.Addr)
+}
+
+func (c *DB) dataSource(addr string) (string, error) {
+	pass := c.Password
+	if c.PasswordFile != "" {
+		data, err := os.ReadFile(c.PasswordFile)
+		if err != nil {
+			return "", fmt.Errorf("read password: %w", err)
+		}
+		pass = strings.TrimRight(string(data), "\r\n")
+	}
+
+	q := url.Values{"sslmode": {c.SSLMode}}
+	for k, v := range map[string]string{
+		"sslrootcert": c.SSLRootCert,
+		"sslcert":     c.SSLCert,
+		"sslkey":      c.SSLKey,
+	} {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	if c.ConnectTimeout > 0 {
+		// connect_timeout is in whole seconds, and 0 means forever, so
+		// round up.
+		q.Set("connect_timeout", strconv.FormatInt(int64((c.ConnectTimeout+time.Second-1)/time.Second), 10))
+	}
+
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.User, pass),
+		Host:     addr,
+		Path:     "/" + c.Name,
+		RawQuery: q.Encode(),
+	}
+	return dsn.String(), nil
+}
+
+// Open opens the primary database and configures its connection pool.
+func (c *DB) Open() (*sqlx.DB, error) {
+	dsn, err := c.DataSource()
+	if err != nil {
+		return nil, err
+	}
+	return c.open(dsn)
+}
+
+// OpenReplicas opens each of the read replicas. Each gets its own
+// connection pool, configured the same way as the primary's.
+func (c *DB) OpenReplicas() ([]*sqlx.DB, error) {
+	replicas := make([]*sqlx.DB, 0, len(c.Replicas))
+	for _, r := range c.Replicas {
+		dsn := r
+		if !strings.Contains(r, "://") {
+			var err error
+			dsn, err = c.dataSource(r)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		db, err := c.open(dsn)
+		if err != nil {
+			for _, db := range replicas {
+				db.Close()
+			}
+			return nil, fmt.Errorf("replica %q: %w", r, err)
+		}
+		replicas = append(replicas, db)
+	}
+	return replicas, nil
+}
+
+func (c *DB) open(dsn string) (*sqlx.DB, error) {
+	db, err := sqlx.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetMaxIdleConns(c.MaxIdleConns)
+	db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+
+	return db, nil
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// GitHub configures access to the GitHub API.
+type GitHub struct {
+	// Token is an OAuth 2 token used to increase the rate limit.
+	Token     string
+	TokenFile string
+
+	// API is the base URL of the API, which can be changed for GitHub
+	// Enterprise.
+	API     string
+	Timeout time.Duration
+}
+
+// RegisterFlags adds flags to fs that set the fields of c.
+func (c *GitHub) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Token, "token", "", "GitHub OAuth 2 token to increase rate limit; prefer -token-file or the environment so that it isn't visible in process listings")
+	fs.StringVar(&c.TokenFile, "token-file", "", "file to read the GitHub token from")
+	fs.StringVar(&c.API, "github-api", "https://api.github.com", "base `URL` of the GitHub API")
+	fs.DurationVar(&c.Timeout, "github-timeout", time.Minute, "maximum time to wait for a response from the GitHub API")
+}
+
+func (c *GitHub) Validate() error {
+	var errs []error
+	if (c.Token != "") && (c.TokenFile != "") {
+		errs = append(errs, errors.New("only one of token and token-file may be set"))
+	}
+	if u, err := url.Parse(c.API); (err != nil) || (u.Scheme == "") || (u.Host == "") {
+		errs = append(errs, fmt.Errorf("github-api %q is not a valid URL", c.API))
+	}
+	if c.Timeout < 0 {
+		errs = append(errs, errors.New("github-timeout must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// GetToken returns the token, reading it from the token file if one
+// was given.
+func (c *GitHub) GetToken() (string, error) {
+	if c.TokenFile == "" {
+		return c.Token, nil
+	}
+
+	data, err := os.ReadFile(c.TokenFile)
+	if err != nil {
+		return "", fmt.Errorf("read token: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"net/http"
+	"time"
+)
+
+// Server configures an HTTP server.
+type Server struct {
+	Addr        string
+	MetricsAddr string
+	GRPCAddr    string
+
+	// TLSCert and TLSKey are files containing a certificate and its
+	// key. If they are set, the server uses HTTPS, and the gRPC API
+	// uses TLS.
+	TLSCert string
+	TLSKey  string
+
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+
+	// DrainDelay is how long the server keeps serving requests after it
+	// starts failing readiness checks and before it stops accepting
+	// new connections, giving load balancers time to notice.
+	DrainDelay time.Duration
+}
+
+// RegisterFlags adds flags to fs that set the fields of c.
+func (c *Server) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Addr, "addr", ":8080", "address to listen on")
+	fs.StringVar(&c.MetricsAddr, "metrics-addr", "", "address to serve Prometheus metrics on at /metrics, or empty to serve them alongside the API")
+	fs.StringVar(&c.GRPCAddr, "grpc-addr", "", "address to serve the gRPC API on, or empty to disable it")
+	fs.StringVar(&c.TLSCert, "tls-cert", "", "certificate file to serve HTTPS with")
+	fs.StringVar(&c.TLSKey, "tls-key", "", "key file for -tls-cert")
+	fs.DurationVar(&c.ReadTimeout, "read-timeout", 10*time.Second, "maximum time to spend reading a request, including its body, 0 for no limit")
+	fs.DurationVar(&c.WriteTimeout, "write-timeout", time.Minute, "maximum time from the end of reading a request's headers to the end of sending its response, 0 for no limit; should be longer than -timeout")
+	fs.DurationVar(&c.IdleTimeout, "idle-timeout", 2*time.Minute, "maximum time to keep idle keep-alive connections open, 0 to use -read-timeout")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", 30*time.Second, "maximum time to wait for in-flight requests to finish when shutting down")
+	fs.DurationVar(&c.DrainDelay, "drain-delay", 0, "time to keep serving requests after failing readiness checks when shutting down, before no longer accepting new connections; should be longer than the interval at which readiness is checked")
+}
+
+func (c *Server) Validate() error {
+	var errs []error
+	if c.Addr == "" {
+		errs = append(errs, errors.New("addr must be set"))
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		errs = append(errs, errors.New("tls-cert and tls-key must be set together"))
+	}
+	if (c.ReadTimeout < 0) || (c.WriteTimeout < 0) || (c.IdleTimeout < 0) || (c.ShutdownTimeout < 0) || (c.DrainDelay < 0) {
+		errs = append(errs, errors.New("timeouts must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// HTTPServer returns a server that serves h on addr with the timeouts
+// from c.
+func (c *Server) HTTPServer(addr string, h http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		ReadTimeout:       c.ReadTimeout,
+		ReadHeaderTimeout: c.ReadTimeout,
+		WriteTimeout:      c.WriteTimeout,
+		IdleTimeout:       c.IdleTimeout,
+	}
+}
+
+// ListenAndServe starts srv, using HTTPS if c has a certificate.
+func (c *Server) ListenAndServe(srv *http.Server) error {
+	if c.TLSCert != "" {
+		return srv.ListenAndServeTLS(c.TLSCert, c.TLSKey)
+	}
+	return srv.ListenAndServe()
+}
@@ -0,0 +1,96 @@
+// Package logging configures structured logging for the bcc commands.
+package logging
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/DeedleFake/backend-code-challenge/bcc"
+)
+
+// Options controls how logs are written.
+type Options struct {
+	// Level is the minimum level of messages to log.
+	Level slog.Level
+
+	// Format is either "text" or "json".
+	Format string
+}
+
+// RegisterFlags adds -log-level and -log-format flags to fs that set
+// the corresponding fields of o.
+func (o *Options) RegisterFlags(fs *flag.FlagSet) {
+	fs.TextVar(&o.Level, "log-level", slog.LevelInfo, "minimum `level` of messages to log: debug, info, warn, or error")
+	fs.StringVar(&o.Format, "log-format", "text", "log `format`: text or json")
+}
+
+func (o *Options) Validate() error {
+	switch o.Format {
+	case "text", "json":
+		return nil
+	default:
+		return fmt.Errorf("unknown log format %q", o.Format)
+	}
+}
+
+// Install replaces the default slog logger with one configured by o
+// that writes to w. The logger adds a request_id attribute to every
+// message logged with a context that carries one, as set by
+// bcc.WithRequestID. Install also redirects the log package's output
+// to the new logger.
+func (o Options) Install(w io.Writer) error {
+	hopts := &slog.HandlerOptions{Level: o.Level}
+
+	var h slog.Handler
+	switch o.Format {
+	case "text", "":
+		h = slog.NewTextHandler(w, hopts)
+	case "json":
+		h = slog.NewJSONHandler(w, hopts)
+	default:
+		return fmt.Errorf("unknown log format %q", o.Format)
+	}
+
+	slog.SetDefault(slog.New(contextHandler{h}))
+	return nil
+}
+
+// Setup is a shortcut for calling o.Install(os.Stderr) and exiting if
+// it fails.
+func (o Options) Setup() {
+	err := o.Install(os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to set up logging: %v\n", err)
+		os.Exit(2)
+	}
+}
+
+// contextHandler adds attributes carried by contexts to records.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := bcc.RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// Fatal logs msg at the error level and exits.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
@@ -0,0 +1,201 @@
+// Package ratelimit implements token bucket rate limiting, with buckets
+// kept either in memory or in a store that is shared between servers.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit is the rate at which requests are allowed. Tokens are added to
+// a bucket at a rate of Tokens per Per, up to Burst, and each request
+// takes one. The zero Limit allows everything.
+type Limit struct {
+	Tokens int
+	Per    time.Duration
+	Burst  int
+}
+
+// ParseLimit parses a limit of the form N/D or N/D:B, such as 10/m or
+// 100/30s:200. D is either a duration or a unit of one, and B is the
+// burst, which defaults to N.
+func ParseLimit(s string) (Limit, error) {
+	rate, burst, hasBurst := strings.Cut(s, ":")
+	n, per, ok := strings.Cut(rate, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("%q is not of the form N/D", s)
+	}
+
+	tokens, err := strconv.Atoi(n)
+	if (err != nil) || (tokens <= 0) {
+		return Limit{}, fmt.Errorf("%q is not a positive number", n)
+	}
+	if (per != "") && ((per[0] < '0') || (per[0] > '9')) {
+		per = "1" + per
+	}
+	d, err := time.ParseDuration(per)
+	if (err != nil) || (d <= 0) {
+		return Limit{}, fmt.Errorf("%q is not a positive duration", per)
+	}
+
+	l := Limit{Tokens: tokens, Per: d, Burst: tokens}
+	if hasBurst {
+		l.Burst, err = strconv.Atoi(burst)
+		if (err != nil) || (l.Burst <= 0) {
+			return Limit{}, fmt.Errorf("%q is not a positive number", burst)
+		}
+	}
+	return l, nil
+}
+
+func (l Limit) String() string {
+	if l.IsZero() {
+		return ""
+	}
+	s := strconv.FormatInt(int64(l.Tokens), 10) + "/" + l.Per.String()
+	if l.Burst != l.Tokens {
+		s += ":" + strconv.FormatInt(int64(l.Burst), 10)
+	}
+	return s
+}
+
+func (l Limit) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText parses text with ParseLimit. Empty text is parsed as
+// the zero Limit.
+func (l *Limit) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*l = Limit{}
+		return nil
+	}
+
+	parsed, err := ParseLimit(string(text))
+	if err != nil {
+		return err
+	}
+	*l = parsed
+	return nil
+}
+
+// IsZero returns true if l doesn't limit anything.
+func (l Limit) IsZero() bool {
+	return (l.Tokens <= 0) || (l.Per <= 0)
+}
+
+// rate returns the number of tokens that are added per second.
+func (l Limit) rate() float64 {
+	return float64(l.Tokens) / l.Per.Seconds()
+}
+
+// Result is the outcome of taking a token from a bucket.
+type Result struct {
+	// Allowed is true if a token was taken.
+	Allowed bool
+
+	// Remaining is the number of whole tokens left in the bucket.
+	Remaining int
+
+	// RetryAfter is how long it will be until a token can be taken, if
+	// one couldn't be.
+	RetryAfter time.Duration
+
+	// Reset is how long it will be until the bucket is full again.
+	Reset time.Duration
+}
+
+// result returns the Result of a take that left tokens in a bucket
+// with limit l.
+func result(l Limit, tokens float64, allowed bool) Result {
+	r := Result{
+		Allowed:   allowed,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration((float64(l.Burst) - tokens) / l.rate() * float64(time.Second)),
+	}
+	if !allowed {
+		r.RetryAfter = time.Duration((1 - tokens) / l.rate() * float64(time.Second))
+	}
+	return r
+}
+
+// Store keeps track of buckets. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// Take takes a token from the bucket stored under key, which is
+	// refilled according to l. Buckets that don't exist yet start out
+	// full.
+	Take(ctx context.Context, key string, l Limit) (Result, error)
+}
+
+// Memory is a Store that keeps buckets in memory, so they only limit
+// requests made to a single server. The zero value is ready to use.
+type Memory struct {
+	m       sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+
+	// now returns the current time. If it is nil, time.Now is used.
+	now func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time
+}
+
+// memorySweepInterval is how often buckets that have refilled are
+// removed from a Memory.
+const memorySweepInterval = time.Minute
+
+func (m *Memory) Take(ctx context.Context, key string, l Limit) (Result, error) {
+	now := time.Now()
+	if m.now != nil {
+		now = m.now()
+	}
+
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	if m.buckets == nil {
+		m.buckets = make(map[string]*bucket)
+		m.swept = now
+	}
+	if now.Sub(m.swept) >= memorySweepInterval {
+		m.sweep(now)
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.Burst), last: now}
+		m.buckets[key] = b
+	}
+
+	b.tokens = min(float64(l.Burst), b.tokens+now.Sub(b.last).Seconds()*l.rate())
+	b.last = now
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+
+	r := result(l, b.tokens, allowed)
+	b.full = now.Add(r.Reset)
+	return r, nil
+}
+
+// sweep removes buckets that have refilled, as they are no different
+// from buckets that don't exist.
+func (m *Memory) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		if !now.Before(b.full) {
+			delete(m.buckets, key)
+		}
+	}
+	m.swept = now
+}
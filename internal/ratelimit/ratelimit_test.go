@@ -0,0 +1,176 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		s     string
+		limit Limit
+		err   bool
+	}{
+		{s: "10/m", limit: Limit{Tokens: 10, Per: time.Minute, Burst: 10}},
+		{s: "100/30s:200", limit: Limit{Tokens: 100, Per: 30 * time.Second, Burst: 200}},
+		{s: "5/1h", limit: Limit{Tokens: 5, Per: time.Hour, Burst: 5}},
+		{s: "1/s:3", limit: Limit{Tokens: 1, Per: time.Second, Burst: 3}},
+		{s: "10", err: true},
+		{s: "0/m", err: true},
+		{s: "-1/m", err: true},
+		{s: "x/m", err: true},
+		{s: "10/", err: true},
+		{s: "10/0s", err: true},
+		{s: "10/fortnight", err: true},
+		{s: "10/m:0", err: true},
+		{s: "10/m:x", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.s, func(t *testing.T) {
+			limit, err := ParseLimit(test.s)
+			if test.err {
+				if err == nil {
+					t.Fatalf("got %v, want an error", limit)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if limit != test.limit {
+				t.Errorf("got %+v, want %+v", limit, test.limit)
+			}
+
+			var text Limit
+			err = text.UnmarshalText([]byte(limit.String()))
+			if err != nil {
+				t.Fatalf("parse %q: %v", limit.String(), err)
+			}
+			if text != limit {
+				t.Errorf("%q parsed as %+v, want %+v", limit.String(), text, limit)
+			}
+		})
+	}
+}
+
+func TestMemory(t *testing.T) {
+	type take struct {
+		after     time.Duration
+		allowed   bool
+		remaining int
+		retry     time.Duration
+		reset     time.Duration
+	}
+
+	tests := []struct {
+		name  string
+		limit Limit
+		takes []take
+	}{
+		{
+			name:  "Burst",
+			limit: Limit{Tokens: 2, Per: 2 * time.Second, Burst: 2},
+			takes: []take{
+				{allowed: true, remaining: 1, reset: time.Second},
+				{allowed: true, remaining: 0, reset: 2 * time.Second},
+				{allowed: false, remaining: 0, retry: time.Second, reset: 2 * time.Second},
+			},
+		},
+		{
+			name:  "Refill",
+			limit: Limit{Tokens: 1, Per: time.Second, Burst: 1},
+			takes: []take{
+				{allowed: true, remaining: 0, reset: time.Second},
+				{after: 500 * time.Millisecond, allowed: false, remaining: 0, retry: 500 * time.Millisecond, reset: 500 * time.Millisecond},
+				{after: 500 * time.Millisecond, allowed: true, remaining: 0, reset: time.Second},
+			},
+		},
+		{
+			name:  "RefillStopsAtBurst",
+			limit: Limit{Tokens: 1, Per: time.Second, Burst: 3},
+			takes: []take{
+				{allowed: true, remaining: 2, reset: time.Second},
+				{after: time.Hour, allowed: true, remaining: 2, reset: time.Second},
+				{allowed: true, remaining: 1, reset: 2 * time.Second},
+				{allowed: true, remaining: 0, reset: 3 * time.Second},
+				{allowed: false, remaining: 0, retry: time.Second, reset: 3 * time.Second},
+			},
+		},
+		{
+			name:  "DeniedTakesNothing",
+			limit: Limit{Tokens: 1, Per: time.Second, Burst: 1},
+			takes: []take{
+				{allowed: true, remaining: 0, reset: time.Second},
+				{after: 250 * time.Millisecond, allowed: false, retry: 750 * time.Millisecond, reset: 750 * time.Millisecond},
+				{after: 250 * time.Millisecond, allowed: false, retry: 500 * time.Millisecond, reset: 500 * time.Millisecond},
+				{after: 500 * time.Millisecond, allowed: true, remaining: 0, reset: time.Second},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			m := &Memory{now: func() time.Time { return now }}
+
+			for i, take := range test.takes {
+				now = now.Add(take.after)
+				r, err := m.Take(context.Background(), "key", test.limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				want := Result{Allowed: take.allowed, Remaining: take.remaining, RetryAfter: take.retry, Reset: take.reset}
+				if !closeResult(r, want) {
+					t.Errorf("take %v: got %+v, want %+v", i, r, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryKeys(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := &Memory{now: func() time.Time { return now }}
+	limit := Limit{Tokens: 1, Per: time.Minute, Burst: 1}
+
+	for _, key := range []string{"a", "b"} {
+		r, _ := m.Take(context.Background(), key, limit)
+		if !r.Allowed {
+			t.Fatalf("first take from %q was denied", key)
+		}
+	}
+	r, _ := m.Take(context.Background(), "a", limit)
+	if r.Allowed {
+		t.Fatal("second take from \"a\" was allowed")
+	}
+}
+
+func TestMemorySweep(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := &Memory{now: func() time.Time { return now }}
+	limit := Limit{Tokens: 1, Per: time.Second, Burst: 1}
+
+	m.Take(context.Background(), "old", limit)
+	now = now.Add(memorySweepInterval)
+	m.Take(context.Background(), "new", limit)
+
+	if _, ok := m.buckets["old"]; ok {
+		t.Error("refilled bucket was not swept")
+	}
+	if _, ok := m.buckets["new"]; !ok {
+		t.Error("new bucket is missing")
+	}
+}
+
+// closeResult returns true if a and b are the same except for rounding
+// errors in their durations.
+func closeResult(a, b Result) bool {
+	close := func(x, y time.Duration) bool {
+		d := x - y
+		return (d > -time.Microsecond) && (d < time.Microsecond)
+	}
+	return (a.Allowed == b.Allowed) && (a.Remaining == b.Remaining) && close(a.RetryAfter, b.RetryAfter) && close(a.Reset, b.Reset)
+}
@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript takes a token from the bucket in KEYS[1] with a rate of
+// ARGV[1] tokens per second and a burst of ARGV[2]. It returns whether
+// a token was taken and the number of tokens left as a string, as
+// numbers returned from scripts are truncated to integers. The
+// server's clock is used so that the servers sharing it don't need to
+// agree on the time.
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens = tonumber(b[1]) or burst
+local last = tonumber(b[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - last) * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'last', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / rate * 1000) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// Redis is a Store that keeps buckets in Redis or anything else that
+// speaks its protocol, which allows them to be shared between multiple
+// servers.
+type Redis struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedis returns a Redis that uses the server at url, such as
+// redis://localhost:6379/0. Every key is prefixed with prefix so that
+// the server can be shared with other applications.
+func NewRedis(url, prefix string) (*Redis, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Redis{
+		client: redis.NewClient(opts),
+		prefix: prefix,
+	}, nil
+}
+
+func (r *Redis) Take(ctx context.Context, key string, l Limit) (Result, error) {
+	vals, err := takeScript.Run(ctx, r.client, []string{r.prefix + key}, l.rate(), l.Burst).Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	if len(vals) != 2 {
+		return Result{}, fmt.Errorf("unexpected result from script: %v", vals)
+	}
+
+	allowed, _ := vals[0].(int64)
+	s, _ := vals[1].(string)
+	tokens, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("parse tokens: %w", err)
+	}
+
+	return result(l, tokens, allowed == 1), nil
+}
+
+// Close closes the connections to the server.
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
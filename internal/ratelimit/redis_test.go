@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestRedis(t *testing.T) {
+	srv := miniredis.RunT(t)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	srv.SetTime(now)
+
+	r, err := NewRedis("redis://"+srv.Addr()+"/0", "test:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+
+	ctx := context.Background()
+	limit := Limit{Tokens: 2, Per: 2 * time.Second, Burst: 2}
+	takes := []struct {
+		after     time.Duration
+		allowed   bool
+		remaining int
+	}{
+		{allowed: true, remaining: 1},
+		{allowed: true, remaining: 0},
+		{allowed: false, remaining: 0},
+		{after: time.Second, allowed: true, remaining: 0},
+		{after: time.Hour, allowed: true, remaining: 1},
+	}
+
+	for i, take := range takes {
+		now = now.Add(take.after)
+		srv.SetTime(now)
+
+		result, err := r.Take(ctx, "key", limit)
+		if err != nil {
+			t.Fatalf("take %v: %v", i, err)
+		}
+		if (result.Allowed != take.allowed) || (result.Remaining != take.remaining) {
+			t.Errorf("take %v: got %+v, want allowed %v with %v remaining", i, result, take.allowed, take.remaining)
+		}
+	}
+
+	if !srv.Exists("test:key") {
+		t.Error("bucket was not stored with its prefix")
+	}
+}